@@ -0,0 +1,130 @@
+// Command capture-history exports the event history of a workflow execution
+// as JSON, in the format worker.WorkflowReplayer reads. The files it writes to
+// pkg/temporal/workflows/basic/testdata/histories are replayed by go test to
+// catch non-deterministic changes to the workflow code.
+//
+// Export an existing execution:
+//
+//	go run ./cmd/capture-history -workflow-id getAddressFromIP-... -out history.json
+//
+// Or start a new GetAddressFromIP execution (a worker must be running), wait
+// for it to finish and export it:
+//
+//	go run ./cmd/capture-history -start Temporal -out history.json
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/natemollica-nm/temporal/internal/config"
+	"github.com/natemollica-nm/temporal/internal/logging"
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	"github.com/natemollica-nm/temporal/pkg/temporal/workflows/basic"
+	enumspb "go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/temporalproto"
+	"go.temporal.io/sdk/client"
+)
+
+func main() {
+	workflowID := flag.String("workflow-id", "", "ID of the workflow execution to export")
+	runID := flag.String("run-id", "", "run ID of the execution; defaults to the latest run")
+	start := flag.String("start", "", "start a GetAddressFromIP execution for this name and export it once it completes")
+	out := flag.String("out", "", "file to write the history to (required)")
+	flag.Parse()
+
+	if *out == "" || (*workflowID == "") == (*start == "") {
+		fmt.Fprintln(os.Stderr, "usage: capture-history (-workflow-id ID [-run-id ID] | -start NAME) -out FILE")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+	slogger, err := logging.New(cfg.Logging, os.Stderr)
+	if err != nil {
+		slog.Error("Failed to create logger", "error", err)
+		os.Exit(1)
+	}
+
+	c, err := client.Dial(client.Options{
+		HostPort:  cfg.Temporal.HostPort,
+		Namespace: cfg.Temporal.Namespace,
+		Logger:    logging.NewSDKLogger(slogger),
+	})
+	if err != nil {
+		slogger.Error("Unable to create Temporal client", "error", err)
+		os.Exit(1)
+	}
+	defer c.Close()
+
+	ctx := context.Background()
+	if *start != "" {
+		*workflowID, *runID, err = runWorkflow(ctx, c, *start)
+		if err != nil {
+			slogger.Error("Unable to run workflow", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	hist, err := fetchHistory(ctx, c, *workflowID, *runID)
+	if err != nil {
+		slogger.Error("Unable to fetch workflow history", "error", err)
+		os.Exit(1)
+	}
+	if err := writeHistory(*out, hist); err != nil {
+		slogger.Error("Unable to write workflow history", "error", err)
+		os.Exit(1)
+	}
+	slogger.Info("Captured workflow history",
+		logging.WorkflowIDKey, *workflowID, "events", len(hist.Events), "file", *out)
+}
+
+// runWorkflow starts GetAddressFromIP and waits for it to complete.
+func runWorkflow(ctx context.Context, c client.Client, name string) (string, string, error) {
+	options := client.StartWorkflowOptions{
+		ID:        "getAddressFromIP-" + uuid.NewString(),
+		TaskQueue: shared.TaskQueueName,
+	}
+	we, err := c.ExecuteWorkflow(ctx, options, basic.GetAddressFromIP, name, basic.Options{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to start workflow: %w", err)
+	}
+	if err := we.Get(ctx, nil); err != nil {
+		return "", "", fmt.Errorf("workflow %s failed: %w", we.GetID(), err)
+	}
+	return we.GetID(), we.GetRunID(), nil
+}
+
+func fetchHistory(ctx context.Context, c client.Client, workflowID, runID string) (*historypb.History, error) {
+	hist := &historypb.History{}
+	iter := c.GetWorkflowHistory(ctx, workflowID, runID, false, enumspb.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		hist.Events = append(hist.Events, event)
+	}
+	return hist, nil
+}
+
+func writeHistory(path string, hist *historypb.History) error {
+	data, err := temporalproto.CustomJSONMarshalOptions{Indent: "  "}.Marshal(hist)
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/natemollica-nm/temporal/internal/logging"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/store"
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	"github.com/natemollica-nm/temporal/pkg/temporal/workflows/batch"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+)
+
+// recordDir is the worker's record directory, where batches that continued as
+// new saved the results of their earlier runs
+var recordDir string
+
+// Start a BatchEnrich workflow without waiting for it
+func handleStartBatch(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		IPs           []string `json:"ips"`
+		File          string   `json:"file"`
+		Concurrency   int      `json:"concurrency"`
+		LookupsPerRun int      `json:"lookupsPerRun"`
+
+		ChildWorkflows    bool   `json:"childWorkflows"`
+		ParentClosePolicy string `json:"parentClosePolicy"`
+
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req := batch.BatchRequest{
+		IPs:           requestData.IPs,
+		File:          requestData.File,
+		Concurrency:   requestData.Concurrency,
+		LookupsPerRun: requestData.LookupsPerRun,
+
+		ChildWorkflows:    requestData.ChildWorkflows,
+		ParentClosePolicy: requestData.ParentClosePolicy,
+	}
+	if err := req.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := req.ValidateRecordStore(recordDir != ""); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	workflowID, err := submissionWorkflowID(r, batchIDPrefix, requestData.ID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	options := client.StartWorkflowOptions{
+		ID:        workflowID,
+		TaskQueue: shared.TaskQueueName,
+		Memo:      map[string]any{shared.RequestMemoKey: requestMetadata(r, "api")},
+	}
+	we, err := executeOrAttach(r.Context(), options, batch.BatchEnrich, req)
+	if err != nil {
+		logger.Error("Failed to start batch", logging.WorkflowIDKey, workflowID, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+	logging.WithWorkflow(logger, we.GetID(), we.GetRunID()).Info("Started batch", "created", we.Created)
+
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"workflowId": we.GetID(),
+		"runId":      we.GetRunID(),
+		"created":    we.Created,
+	})
+}
+
+// Report the progress of a batch, and its results once it has completed
+func handleGetBatch(w http.ResponseWriter, r *http.Request) {
+	workflowID := r.PathValue("id")
+
+	// The latest run of the chain started by continue-as-new
+	desc, err := temporalClient.DescribeWorkflowExecution(r.Context(), workflowID, "")
+	if err != nil {
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+	status := desc.GetWorkflowExecutionInfo().GetStatus()
+
+	switch status {
+	case enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING:
+		value, err := temporalClient.QueryWorkflow(r.Context(), workflowID, "", batch.ProgressQuery)
+		if err != nil {
+			writeError(w, temporalErrorStatus(err), err.Error())
+			return
+		}
+		var progress batch.Progress
+		if err := value.Get(&progress); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": status.String(), "progress": progress})
+	case enumspb.WORKFLOW_EXECUTION_STATUS_COMPLETED:
+		var result batch.BatchResult
+		if err := temporalClient.GetWorkflow(r.Context(), workflowID, "").Get(r.Context(), &result); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		results, err := batchResults(recordDir, result)
+		if err != nil {
+			logger.Error("Failed to read batch records", logging.WorkflowIDKey, workflowID, "error", err)
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"status":   status.String(),
+			"progress": result.Progress,
+			"results":  results,
+			"records":  result.Records,
+		})
+	default:
+		// Failed, cancelled, terminated or timed out
+		err := temporalClient.GetWorkflow(r.Context(), workflowID, "").Get(r.Context(), nil)
+		response := map[string]any{"status": status.String()}
+		if err != nil {
+			response["error"] = err.Error()
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+// batchResults merges the results the earlier runs of a batch saved in dir
+// with those of its last run, into the results of the whole batch.
+func batchResults(dir string, result batch.BatchResult) (map[string]batch.IPResult, error) {
+	results := map[string]batch.IPResult{}
+	for _, key := range result.Records {
+		record, err := store.ReadRecord(dir, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read record %q: %w", key, err)
+		}
+		if err := json.Unmarshal(record, &results); err != nil {
+			return nil, fmt.Errorf("failed to decode record %q: %w", key, err)
+		}
+	}
+	maps.Copy(results, result.Results)
+	return results, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/store"
+	"github.com/natemollica-nm/temporal/pkg/temporal/workflows/batch"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestBatchResults(t *testing.T) {
+	dir := t.TempDir()
+	activities := &store.StoreActivities{Dir: dir}
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(activities)
+
+	// Two earlier runs saved their results before continuing as new
+	records := []json.RawMessage{
+		json.RawMessage(`{"203.0.113.1":{"status":"succeeded","isp":"Example Fiber"},"not-an-ip":{"status":"invalid"}}`),
+		json.RawMessage(`{"203.0.113.2":{"status":"failed","error":"reserved range"}}`),
+	}
+	var keys []string
+	for run, record := range records {
+		key := batch.ResultRecordKey("batch-1", run)
+		if _, err := env.ExecuteActivity(activities.SaveRecord, key, record, time.Now().UnixNano()); err != nil {
+			t.Fatalf("SaveRecord: %v", err)
+		}
+		keys = append(keys, key)
+	}
+
+	result := batch.BatchResult{
+		Results: map[string]batch.IPResult{"203.0.113.3": {Status: batch.StatusSucceeded}},
+		Records: keys,
+	}
+	results, err := batchResults(dir, result)
+	if err != nil {
+		t.Fatalf("batchResults: %v", err)
+	}
+
+	want := map[string]string{
+		"203.0.113.1": batch.StatusSucceeded,
+		"not-an-ip":   batch.StatusInvalid,
+		"203.0.113.2": batch.StatusFailed,
+		"203.0.113.3": batch.StatusSucceeded,
+	}
+	if len(results) != len(want) {
+		t.Errorf("results = %+v, want the IPs of every run", results)
+	}
+	for addr, status := range want {
+		if results[addr].Status != status {
+			t.Errorf("status of %s = %q, want %q", addr, results[addr].Status, status)
+		}
+	}
+
+	// Without the directory the saved runs cannot be read
+	if _, err := batchResults("", result); err == nil {
+		t.Error("batchResults succeeded without a record directory")
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	enumspb "go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	defaultListPageSize = 20
+	maxListPageSize     = 100
+)
+
+// workflowFilter selects the executions listed by GET /api/workflows and the
+// history page.
+type workflowFilter struct {
+	WorkflowType string
+	Status       enumspb.WorkflowExecutionStatus
+	From, To     time.Time
+	Submitter    string
+	IP           string
+	CountryCode  string
+	Provider     string
+	PageSize     int
+	PageToken    []byte
+}
+
+// parseWorkflowFilter reads a filter from the query parameters type, status,
+// from, to, submitter, ip, country, provider, pageSize and pageToken.
+func parseWorkflowFilter(q url.Values) (workflowFilter, error) {
+	f := workflowFilter{
+		WorkflowType: strings.TrimSpace(q.Get("type")),
+		Submitter:    strings.TrimSpace(q.Get("submitter")),
+		IP:           strings.TrimSpace(q.Get("ip")),
+		CountryCode:  strings.ToUpper(strings.TrimSpace(q.Get("country"))),
+		Provider:     strings.TrimSpace(q.Get("provider")),
+		PageSize:     defaultListPageSize,
+	}
+
+	if s := q.Get("status"); s != "" {
+		status, err := enumspb.WorkflowExecutionStatusFromString(s)
+		if err != nil || status == enumspb.WORKFLOW_EXECUTION_STATUS_UNSPECIFIED {
+			return f, fmt.Errorf("invalid status %q", s)
+		}
+		f.Status = status
+	}
+
+	var err error
+	if f.From, err = parseFilterTime(q.Get("from")); err != nil {
+		return f, fmt.Errorf("invalid from: %w", err)
+	}
+	if f.To, err = parseFilterTime(q.Get("to")); err != nil {
+		return f, fmt.Errorf("invalid to: %w", err)
+	}
+
+	if s := q.Get("pageSize"); s != "" {
+		f.PageSize, err = strconv.Atoi(s)
+		if err != nil || f.PageSize < 1 || f.PageSize > maxListPageSize {
+			return f, fmt.Errorf("pageSize must be between 1 and %d", maxListPageSize)
+		}
+	}
+
+	if s := q.Get("pageToken"); s != "" {
+		if f.PageToken, err = base64.URLEncoding.DecodeString(s); err != nil {
+			return f, errors.New("invalid pageToken")
+		}
+	}
+	return f, nil
+}
+
+// parseFilterTime accepts RFC 3339 and the value of a datetime-local input,
+// which is read as UTC.
+func parseFilterTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02T15:04", s)
+}
+
+// query renders f as a visibility list filter.
+func (f workflowFilter) query() string {
+	var clauses []string
+	if f.WorkflowType != "" {
+		clauses = append(clauses, "WorkflowType = "+visibilityString(f.WorkflowType))
+	}
+	if f.Status != enumspb.WORKFLOW_EXECUTION_STATUS_UNSPECIFIED {
+		clauses = append(clauses, "ExecutionStatus = "+visibilityString(f.Status.String()))
+	}
+	if !f.From.IsZero() {
+		clauses = append(clauses, "StartTime >= "+visibilityString(f.From.UTC().Format(time.RFC3339)))
+	}
+	if !f.To.IsZero() {
+		clauses = append(clauses, "StartTime <= "+visibilityString(f.To.UTC().Format(time.RFC3339)))
+	}
+	keywords := []struct {
+		key   temporal.SearchAttributeKeyKeyword
+		value string
+	}{
+		{shared.SubmitterNameKey, f.Submitter},
+		{shared.ResolvedIPKey, f.IP},
+		{shared.CountryCodeKey, f.CountryCode},
+		{shared.ProviderUsedKey, f.Provider},
+	}
+	for _, kw := range keywords {
+		if kw.value != "" {
+			clauses = append(clauses, kw.key.GetName()+" = "+visibilityString(kw.value))
+		}
+	}
+	return strings.Join(clauses, " AND ")
+}
+
+// visibilityString quotes s as a string literal of the visibility query language
+func visibilityString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// workflowSummary is one execution in a list response
+type workflowSummary struct {
+	WorkflowID    string     `json:"workflowId"`
+	RunID         string     `json:"runId"`
+	Type          string     `json:"type"`
+	Status        string     `json:"status"`
+	StartTime     time.Time  `json:"startTime"`
+	CloseTime     *time.Time `json:"closeTime,omitempty"`
+	SubmitterName string     `json:"submitterName,omitempty"`
+	ResolvedIP    string     `json:"resolvedIp,omitempty"`
+	CountryCode   string     `json:"countryCode,omitempty"`
+	ISP           string     `json:"isp,omitempty"`
+	ProviderUsed  string     `json:"providerUsed,omitempty"`
+
+	Request *shared.RequestMetadata `json:"request,omitempty"`
+}
+
+func newWorkflowSummary(info *workflowpb.WorkflowExecutionInfo) workflowSummary {
+	summary := workflowSummary{
+		WorkflowID: info.GetExecution().GetWorkflowId(),
+		RunID:      info.GetExecution().GetRunId(),
+		Type:       info.GetType().GetName(),
+		Status:     info.GetStatus().String(),
+		StartTime:  info.GetStartTime().AsTime(),
+	}
+	if info.GetCloseTime() != nil {
+		closeTime := info.GetCloseTime().AsTime()
+		summary.CloseTime = &closeTime
+	}
+
+	// Search attributes are never encoded by codecs; memos are
+	dc := converter.GetDefaultDataConverter()
+	indexed := info.GetSearchAttributes().GetIndexedFields()
+	for key, value := range map[temporal.SearchAttributeKeyKeyword]*string{
+		shared.SubmitterNameKey: &summary.SubmitterName,
+		shared.ResolvedIPKey:    &summary.ResolvedIP,
+		shared.CountryCodeKey:   &summary.CountryCode,
+		shared.ISPKey:           &summary.ISP,
+		shared.ProviderUsedKey:  &summary.ProviderUsed,
+	} {
+		if payload, ok := indexed[key.GetName()]; ok {
+			_ = dc.FromPayload(payload, value)
+		}
+	}
+	if payload, ok := info.GetMemo().GetFields()[shared.RequestMemoKey]; ok {
+		var request shared.RequestMetadata
+		if dataConverter.FromPayload(payload, &request) == nil {
+			summary.Request = &request
+		}
+	}
+	return summary
+}
+
+// listWorkflows returns one page of executions matching f and the token of
+// the next page, empty on the last one.
+func listWorkflows(r *http.Request, f workflowFilter) ([]workflowSummary, string, error) {
+	resp, err := temporalClient.ListWorkflow(r.Context(), &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      int32(f.PageSize),
+		NextPageToken: f.PageToken,
+		Query:         f.query(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	summaries := make([]workflowSummary, 0, len(resp.GetExecutions()))
+	for _, info := range resp.GetExecutions() {
+		summaries = append(summaries, newWorkflowSummary(info))
+	}
+	return summaries, base64.URLEncoding.EncodeToString(resp.GetNextPageToken()), nil
+}
+
+// List past and running workflows
+func handleListWorkflows(w http.ResponseWriter, r *http.Request) {
+	f, err := parseWorkflowFilter(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	summaries, nextPageToken, err := listWorkflows(r, f)
+	if err != nil {
+		logger.Error("Failed to list workflows", "query", f.query(), "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"workflows":     summaries,
+		"nextPageToken": nextPageToken,
+	})
+}
+
+var historyRowsTemplate = template.Must(template.New("rows").Parse(`
+{{- range .Workflows}}
+<tr>
+    <td>{{.StartTime.Format "2006-01-02 15:04:05"}}</td>
+    <td>{{.SubmitterName}}</td>
+    <td>{{.ResolvedIP}}</td>
+    <td>{{.CountryCode}}</td>
+    <td>{{.ISP}}</td>
+    <td>{{.Type}}</td>
+    <td class="status-{{.Status}}">{{.Status}}</td>
+    <td><code>{{.WorkflowID}}</code></td>
+</tr>
+{{- else}}
+<tr><td colspan="8">No workflows found</td></tr>
+{{- end}}
+{{- if .NextPage}}
+<tr id="more">
+    <td colspan="8">
+        <button hx-get="/history/rows?{{.NextPage}}" hx-target="#more" hx-swap="outerHTML">Load more</button>
+    </td>
+</tr>
+{{- end}}
+`))
+
+// Render history table rows for HTMX, ending with a button loading the next page
+func handleHistoryRows(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+
+	f, err := parseWorkflowFilter(r.URL.Query())
+	if err != nil {
+		fmt.Fprintf(w, `<tr><td colspan="8" class="error">%s</td></tr>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+
+	summaries, nextPageToken, err := listWorkflows(r, f)
+	if err != nil {
+		logger.Error("Failed to list workflows", "query", f.query(), "error", err)
+		fmt.Fprintf(w, `<tr><td colspan="8" class="error">Error: %s</td></tr>`, template.HTMLEscapeString(err.Error()))
+		return
+	}
+
+	var nextPage template.URL
+	if nextPageToken != "" {
+		q := r.URL.Query()
+		q.Set("pageToken", nextPageToken)
+		nextPage = template.URL(q.Encode())
+	}
+
+	err = historyRowsTemplate.Execute(w, struct {
+		Workflows []workflowSummary
+		NextPage  template.URL
+	}{summaries, nextPage})
+	if err != nil {
+		logger.Error("Failed to render history", "error", err)
+	}
+}
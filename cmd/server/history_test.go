@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestWorkflowFilterQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  string
+		want    string
+		wantErr bool
+	}{
+		{name: "no filter", params: "", want: ""},
+		{
+			name:   "all filters",
+			params: "type=GetAddressFromIP&status=Completed&from=2026-01-02T03:04&to=2026-01-03T00:00:00Z&submitter=Ada",
+			want: "WorkflowType = 'GetAddressFromIP' AND ExecutionStatus = 'Completed'" +
+				" AND StartTime >= '2026-01-02T03:04:00Z' AND StartTime <= '2026-01-03T00:00:00Z'" +
+				" AND SubmitterName = 'Ada'",
+		},
+		{
+			name:   "search attributes",
+			params: "ip=198.51.100.20&country=fr&provider=ip-api",
+			want:   "ResolvedIP = '198.51.100.20' AND CountryCode = 'FR' AND ProviderUsed = 'ip-api'",
+		},
+		{name: "escapes quotes", params: `submitter=O'Brien\`, want: `SubmitterName = 'O\'Brien\\'`},
+		{name: "unknown status", params: "status=Sleeping", wantErr: true},
+		{name: "unspecified status", params: "status=Unspecified", wantErr: true},
+		{name: "bad time", params: "from=yesterday", wantErr: true},
+		{name: "page size too large", params: "pageSize=1000", wantErr: true},
+		{name: "bad page token", params: "pageToken=!!", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.params)
+			if err != nil {
+				t.Fatalf("parse %q: %v", tt.params, err)
+			}
+			f, err := parseWorkflowFilter(q)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseWorkflowFilter(%q) succeeded, want error", tt.params)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseWorkflowFilter(%q): %v", tt.params, err)
+			}
+			if got := f.query(); got != tt.want {
+				t.Errorf("query = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/natemollica-nm/temporal/internal/config"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+)
+
+// Prefixes of the workflow IDs the server generates
+const (
+	workflowIDPrefix = "getAddressFromIP-"
+	batchIDPrefix    = "batchEnrich-"
+)
+
+const (
+	// idempotencyKeyHeader names the request header that makes retried
+	// submissions attach to the execution of the first one.
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	maxWorkflowIDLength = 255
+)
+
+// workflowIDPolicies decide what a submission whose workflow ID is already
+// taken does
+type workflowIDPolicies struct {
+	Reuse    enumspb.WorkflowIdReusePolicy
+	Conflict enumspb.WorkflowIdConflictPolicy
+}
+
+var idPolicies workflowIDPolicies
+
+// parseWorkflowIDPolicies validates the policies of cfg
+func parseWorkflowIDPolicies(cfg config.ServerConfig) (workflowIDPolicies, error) {
+	var p workflowIDPolicies
+	switch cfg.WorkflowIDReusePolicy {
+	case "allow-duplicate":
+		p.Reuse = enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE
+	case "allow-duplicate-failed-only":
+		p.Reuse = enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE_FAILED_ONLY
+	case "reject-duplicate":
+		p.Reuse = enumspb.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE
+	default:
+		return p, fmt.Errorf("unknown workflow ID reuse policy %q", cfg.WorkflowIDReusePolicy)
+	}
+	switch cfg.WorkflowIDConflictPolicy {
+	case "fail":
+		p.Conflict = enumspb.WORKFLOW_ID_CONFLICT_POLICY_FAIL
+	case "use-existing":
+		p.Conflict = enumspb.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING
+	case "terminate-existing":
+		p.Conflict = enumspb.WORKFLOW_ID_CONFLICT_POLICY_TERMINATE_EXISTING
+	default:
+		return p, fmt.Errorf("unknown workflow ID conflict policy %q", cfg.WorkflowIDConflictPolicy)
+	}
+	return p, nil
+}
+
+// submissionWorkflowID returns the workflow ID for a submission. An explicit
+// id is used as is, an Idempotency-Key header is prefixed like generated IDs,
+// and requests carrying neither get a fresh ID.
+func submissionWorkflowID(r *http.Request, prefix, explicitID string) (string, error) {
+	explicitID = strings.TrimSpace(explicitID)
+	key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+
+	var workflowID string
+	switch {
+	case explicitID != "" && key != "" && explicitID != prefix+key:
+		return "", fmt.Errorf("id and %s header name different workflows", idempotencyKeyHeader)
+	case explicitID != "":
+		workflowID = explicitID
+	case key != "":
+		workflowID = prefix + key
+	default:
+		return prefix + uuid.New().String(), nil
+	}
+
+	if len(workflowID) > maxWorkflowIDLength {
+		return "", fmt.Errorf("workflow ID must be at most %d bytes", maxWorkflowIDLength)
+	}
+	if strings.IndexFunc(workflowID, unicode.IsControl) >= 0 {
+		return "", errors.New("workflow ID must not contain control characters")
+	}
+	return workflowID, nil
+}
+
+// workflowStart is the execution serving a submission
+type workflowStart struct {
+	client.WorkflowRun
+	// Created is false when the submission attached to an existing execution
+	Created bool
+}
+
+// executeOrAttach starts a workflow, or attaches to the execution that
+// already holds options.ID when the ID policies keep a new one from starting.
+//
+// The server applies the "use-existing" conflict policy without telling the
+// SDK whether it started anything, so it is resolved here instead: the start
+// fails on a running execution and the submission attaches to it.
+func executeOrAttach(ctx context.Context, options client.StartWorkflowOptions, workflow any, args ...any) (workflowStart, error) {
+	useExisting := idPolicies.Conflict == enumspb.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING
+	options.WorkflowIDReusePolicy = idPolicies.Reuse
+	if !useExisting {
+		options.WorkflowIDConflictPolicy = idPolicies.Conflict
+	}
+	options.WorkflowExecutionErrorWhenAlreadyStarted = true
+
+	we, err := temporalClient.ExecuteWorkflow(ctx, options, workflow, args...)
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if !errors.As(err, &alreadyStarted) {
+		return workflowStart{WorkflowRun: we, Created: true}, err
+	}
+
+	// The error covers both a running execution and a closed one the reuse
+	// policy will not replace. Only the first is a conflict.
+	if !useExisting {
+		desc, err := temporalClient.DescribeWorkflowExecution(ctx, options.ID, alreadyStarted.RunId)
+		if err != nil {
+			return workflowStart{}, err
+		}
+		if desc.GetWorkflowExecutionInfo().GetStatus() == enumspb.WORKFLOW_EXECUTION_STATUS_RUNNING {
+			return workflowStart{}, alreadyStarted
+		}
+	}
+	return workflowStart{WorkflowRun: temporalClient.GetWorkflow(ctx, options.ID, alreadyStarted.RunId)}, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubmissionWorkflowID(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		explicitID string
+		want       string
+		wantErr    bool
+	}{
+		{name: "idempotency key", key: "order-42", want: "getAddressFromIP-order-42"},
+		{name: "explicit id", explicitID: "lookup-1", want: "lookup-1"},
+		{name: "matching id and key", key: "order-42", explicitID: "getAddressFromIP-order-42", want: "getAddressFromIP-order-42"},
+		{name: "conflicting id and key", key: "order-42", explicitID: "lookup-1", wantErr: true},
+		{name: "too long", explicitID: strings.Repeat("x", maxWorkflowIDLength+1), wantErr: true},
+		{name: "control characters", explicitID: "lookup\x00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/api", nil)
+			if tt.key != "" {
+				r.Header.Set(idempotencyKeyHeader, tt.key)
+			}
+
+			got, err := submissionWorkflowID(r, workflowIDPrefix, tt.explicitID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("submissionWorkflowID = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("submissionWorkflowID: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("submissionWorkflowID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Without a key or id every submission gets its own execution
+	r := httptest.NewRequest("POST", "/api", nil)
+	first, _ := submissionWorkflowID(r, workflowIDPrefix, "")
+	second, _ := submissionWorkflowID(r, workflowIDPrefix, "")
+	if first == second || !strings.HasPrefix(first, workflowIDPrefix) {
+		t.Errorf("generated IDs %q and %q, want distinct IDs prefixed %q", first, second, workflowIDPrefix)
+	}
+}
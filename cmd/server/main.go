@@ -3,67 +3,144 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 
-	"github.com/google/uuid"
+	"github.com/natemollica-nm/temporal/internal/codec"
 	"github.com/natemollica-nm/temporal/internal/config"
+	"github.com/natemollica-nm/temporal/internal/logging"
 	"github.com/natemollica-nm/temporal/internal/metrics"
+	"github.com/natemollica-nm/temporal/internal/tracing"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
 	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
 	"github.com/natemollica-nm/temporal/pkg/temporal/workflows/basic"
+	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/sdk/client"
-
-	sdktally "go.temporal.io/sdk/contrib/tally"
-	sdklog "go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
 )
 
-var temporalClient client.Client
+var (
+	temporalClient client.Client
+	// dataConverter decodes the payloads the server reads outside of the
+	// client, such as memos
+	dataConverter converter.DataConverter
+	// omitSensitiveAttributes keeps personal data out of the plaintext search
+	// attributes while payloads are encrypted
+	omitSensitiveAttributes bool
+	logger                  *slog.Logger
+)
 
 // Initialize Temporal Client
-func initializeTemporal() error {
-	// Load configuration
-	cfg := config.Default()
-	
-	// Create logger - use nil for default logger
-	var logger sdklog.Logger
-	
-	// Initialize metrics
-	if err := metrics.Initialize(cfg.Metrics, logger); err != nil {
-		return fmt.Errorf("failed to initialize metrics: %w", err)
+func initializeTemporal(cfg config.Config, metricsProvider *metrics.Provider, tracingProvider *tracing.Provider, codecProvider *codec.Provider) error {
+	tracingInterceptor, err := tracingProvider.Interceptor()
+	if err != nil {
+		return fmt.Errorf("failed to create tracing interceptor: %w", err)
 	}
 
-	var err error
+	dataConverter = codecProvider.DataConverter()
+	omitSensitiveAttributes = codecProvider.Encrypts()
 	temporalClient, err = client.Dial(client.Options{
-		HostPort:       cfg.Temporal.HostPort,
-		Namespace:      cfg.Temporal.Namespace,
-		MetricsHandler: sdktally.NewMetricsHandler(metrics.GetScope()),
-		Logger:         logger,
+		HostPort:         cfg.Temporal.HostPort,
+		Namespace:        cfg.Temporal.Namespace,
+		MetricsHandler:   metricsProvider.MetricsHandler(),
+		Logger:           logging.NewSDKLogger(logger),
+		Interceptors:     []interceptor.ClientInterceptor{tracingInterceptor},
+		DataConverter:    dataConverter,
+		FailureConverter: codecProvider.FailureConverter(),
 	})
 	return err
 }
 
-// Start the Temporal Workflow
-func startWorkflow(name string) (string, error) {
-	workflowID := "getAddressFromIP-" + uuid.New().String()
+// requestMetadata describes r for the memo of the workflow it starts
+func requestMetadata(r *http.Request, source string) shared.RequestMetadata {
+	return shared.RequestMetadata{
+		Source:      source,
+		RemoteAddr:  r.RemoteAddr,
+		UserAgent:   r.UserAgent(),
+		SubmittedAt: time.Now().UTC(),
+	}
+}
+
+// Start the Temporal Workflow, or attach to the execution already holding
+// workflowID
+func startWorkflow(ctx context.Context, name, workflowID string, opts basic.Options, metadata shared.RequestMetadata) (workflowStart, error) {
+	// The workflow upserts the remaining attributes as results arrive
+	attributes := []temporal.SearchAttributeUpdate{shared.ProviderUsedKey.ValueSet(ip.Provider)}
+	if !omitSensitiveAttributes {
+		attributes = append(attributes, shared.SubmitterNameKey.ValueSet(name))
+	}
+	opts.OmitSensitiveAttributes = omitSensitiveAttributes
+
 	options := client.StartWorkflowOptions{
-		ID:        workflowID,
-		TaskQueue: shared.TaskQueueName,
+		ID:                    workflowID,
+		TaskQueue:             shared.TaskQueueName,
+		TypedSearchAttributes: temporal.NewSearchAttributes(attributes...),
+		Memo:                  map[string]any{shared.RequestMemoKey: metadata},
 	}
 
-	we, err := temporalClient.ExecuteWorkflow(context.Background(), options, basic.GetAddressFromIP, name)
+	we, err := executeOrAttach(ctx, options, basic.GetAddressFromIP, name, opts)
 	if err != nil {
-		return "", err
+		logger.Error("Failed to start workflow", logging.WorkflowIDKey, workflowID, "error", err)
+		return we, err
 	}
 
+	wfLogger := logging.WithWorkflow(logger, we.GetID(), we.GetRunID())
+	if we.Created {
+		wfLogger.Info("Started workflow")
+	} else {
+		wfLogger.Info("Attached to existing workflow")
+	}
+	return we, nil
+}
+
+// Wait for the result of a workflow started by startWorkflow
+func workflowResult(ctx context.Context, we workflowStart) (string, error) {
 	var result string
-	err = we.Get(context.Background(), &result)
+	err := we.Get(ctx, &result)
+	if err != nil {
+		logging.WithWorkflow(logger, we.GetID(), we.GetRunID()).Error("Workflow failed", "error", err)
+	}
 	return result, err
 }
 
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs every request once it has been served
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logger.Info("HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration", time.Since(start),
+		)
+	})
+}
+
 // Handle HTMX form submission
 func handleSubmit(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -78,13 +155,33 @@ func handleSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := startWorkflow(name)
+	workflowID, err := submissionWorkflowID(r, workflowIDPrefix, r.FormValue("id"))
+	if err != nil {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<p class="error">%s</p>`, html.EscapeString(err.Error()))
+		return
+	}
+
+	we, err := startWorkflow(r.Context(), name, workflowID, basic.Options{}, requestMetadata(r, "form"))
+	var result string
+	if err == nil {
+		result, err = workflowResult(r.Context(), we)
+	}
+	if cancelled, ok := cancelledResult(err); ok {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<p class="error">Cancelled during %s. %s</p>`,
+			html.EscapeString(cancelled.Status.Stage), html.EscapeString(cancelled.Result))
+		return
+	}
 	if err != nil {
 		w.Header().Set("Content-Type", "text/html")
 		fmt.Fprintf(w, `<p class="error">Error: %s</p>`, html.EscapeString(err.Error()))
 		return
 	}
 
+	if !we.Created {
+		result += " (from an earlier submission)"
+	}
 	w.Header().Set("Content-Type", "text/html")
 	fmt.Fprintf(w, `<p class="success">%s</p>`, html.EscapeString(result))
 }
@@ -92,42 +189,243 @@ func handleSubmit(w http.ResponseWriter, r *http.Request) {
 // Handle API request
 func handleAPI(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Method not allowed"})
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
 	var requestData struct {
 		Name string `json:"name"`
+		// ID is the workflow ID to use; repeating it attaches to the same
+		// execution like the Idempotency-Key header does
+		ID string `json:"id"`
+		// CallbackURL receives the result once the workflow has it, and the
+		// request returns without waiting for it
+		CallbackURL string `json:"callbackUrl"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&requestData)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
+		writeError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
 	requestData.Name = strings.TrimSpace(requestData.Name)
 	if requestData.Name == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Name is required"})
+		writeError(w, http.StatusBadRequest, "Name is required")
 		return
 	}
 
-	result, err := startWorkflow(requestData.Name)
+	requestData.CallbackURL = strings.TrimSpace(requestData.CallbackURL)
+	if err := validWebhookURL("callbackUrl", requestData.CallbackURL); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	workflowID, err := submissionWorkflowID(r, workflowIDPrefix, requestData.ID)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	opts := basic.Options{CallbackURL: requestData.CallbackURL}
+	we, err := startWorkflow(r.Context(), requestData.Name, workflowID, opts, requestMetadata(r, "api"))
+	if err == nil && opts.CallbackURL != "" {
+		writeJSON(w, http.StatusAccepted, map[string]any{
+			"workflowId": we.GetID(),
+			"runId":      we.GetRunID(),
+			"created":    we.Created,
+		})
+		return
+	}
+	var result string
+	if err == nil {
+		result, err = workflowResult(r.Context(), we)
+	}
+	var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &alreadyStarted) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "Workflow is already running", "workflowId": workflowID})
+		return
+	}
+	if cancelled, ok := cancelledResult(err); ok {
+		writeJSON(w, http.StatusConflict, map[string]any{"error": "Workflow cancelled", "cancelled": cancelled})
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"result":     result,
+		"workflowId": we.GetID(),
+		"runId":      we.GetRunID(),
+		"created":    we.Created,
+	})
+}
+
+// cancelledResult extracts what a cancelled GetAddressFromIP reported
+func cancelledResult(err error) (basic.CancelledResult, bool) {
+	var result basic.CancelledResult
+	var canceledErr *temporal.CanceledError
+	if !errors.As(err, &canceledErr) || !canceledErr.HasDetails() {
+		return result, false
+	}
+	if err := canceledErr.Details(&result); err != nil {
+		return result, false
+	}
+	return result, true
+}
+
+// Request cancellation of a workflow; it cleans up and reports what it had
+func handleCancel(w http.ResponseWriter, r *http.Request) {
+	workflowID := r.PathValue("id")
+
+	err := temporalClient.CancelWorkflow(r.Context(), workflowID, r.URL.Query().Get("runId"))
+	if err != nil {
+		logger.Error("Failed to cancel workflow", logging.WorkflowIDKey, workflowID, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	logger.Info("Requested workflow cancellation", logging.WorkflowIDKey, workflowID)
+	writeJSON(w, http.StatusAccepted, map[string]string{"workflowId": workflowID, "status": "cancel requested"})
+}
+
+// Terminate a workflow immediately, without running any cleanup
+func handleTerminate(w http.ResponseWriter, r *http.Request) {
+	workflowID := r.PathValue("id")
+
+	var requestData struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	requestData.Reason = strings.TrimSpace(requestData.Reason)
+	if requestData.Reason == "" {
+		writeError(w, http.StatusBadRequest, "Reason is required")
+		return
+	}
+
+	err := temporalClient.TerminateWorkflow(r.Context(), workflowID, r.URL.Query().Get("runId"), requestData.Reason)
+	if err != nil {
+		logger.Error("Failed to terminate workflow", logging.WorkflowIDKey, workflowID, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	logger.Info("Terminated workflow", logging.WorkflowIDKey, workflowID, "reason", requestData.Reason)
+	writeJSON(w, http.StatusOK, map[string]string{"workflowId": workflowID, "status": "terminated"})
+}
+
+// Send a signal to a workflow; the optional JSON body is the signal argument
+func handleSignal(w http.ResponseWriter, r *http.Request) {
+	workflowID, name := r.PathValue("id"), r.PathValue("name")
+
+	var arg any
+	if err := json.NewDecoder(r.Body).Decode(&arg); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	err := temporalClient.SignalWorkflow(r.Context(), workflowID, r.URL.Query().Get("runId"), name, arg)
+	if err != nil {
+		logger.Error("Failed to signal workflow", logging.WorkflowIDKey, workflowID, "signal", name, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	logger.Info("Signaled workflow", logging.WorkflowIDKey, workflowID, "signal", name)
+	writeJSON(w, http.StatusAccepted, map[string]string{"workflowId": workflowID, "signal": name})
+}
+
+// Query a workflow and return the decoded answer
+func handleQuery(w http.ResponseWriter, r *http.Request) {
+	workflowID, name := r.PathValue("id"), r.PathValue("name")
+
+	value, err := temporalClient.QueryWorkflow(r.Context(), workflowID, r.URL.Query().Get("runId"), name)
+	if err != nil {
+		logger.Error("Failed to query workflow", logging.WorkflowIDKey, workflowID, "query", name, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	var result any
+	if err := value.Get(&result); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"result": result})
+}
+
+// Run a workflow update and wait for its result; the optional JSON body is the
+// update argument
+func handleUpdate(w http.ResponseWriter, r *http.Request) {
+	workflowID, name := r.PathValue("id"), r.PathValue("name")
+
+	var arg any
+	if err := json.NewDecoder(r.Body).Decode(&arg); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	var args []any
+	if arg != nil {
+		args = append(args, arg)
+	}
+
+	handle, err := temporalClient.UpdateWorkflow(r.Context(), client.UpdateWorkflowOptions{
+		WorkflowID:   workflowID,
+		RunID:        r.URL.Query().Get("runId"),
+		UpdateName:   name,
+		Args:         args,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err != nil {
+		logger.Error("Failed to update workflow", logging.WorkflowIDKey, workflowID, "update", name, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	var result any
+	if err := handle.Get(r.Context(), &result); err != nil {
+		// Rejected by the validator or failed in the handler
+		logger.Warn("Workflow update failed", logging.WorkflowIDKey, workflowID, "update", name, "error", err)
+		var appErr *temporal.ApplicationError
+		if errors.As(err, &appErr) {
+			writeError(w, http.StatusUnprocessableEntity, appErr.Error())
+			return
+		}
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	logger.Info("Updated workflow", logging.WorkflowIDKey, workflowID, "update", name, "updateId", handle.UpdateID())
+	writeJSON(w, http.StatusOK, map[string]any{"result": result})
+}
+
+// temporalErrorStatus maps a Temporal client error to an HTTP status code
+func temporalErrorStatus(err error) int {
+	var notFound *serviceerror.NotFound
+	var queryFailed *serviceerror.QueryFailed
+	switch {
+	case errors.As(err, &notFound):
+		return http.StatusNotFound
+	case errors.As(err, &queryFailed):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"result": result})
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
 }
 
 // Serve static files with proper MIME types
@@ -166,16 +464,153 @@ func serveStaticFiles(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
-	err := initializeTemporal()
+	// Load configuration
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
 	if err != nil {
-		log.Fatalf("Failed to initialize Temporal client: %v", err)
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
 	}
 
-	http.HandleFunc("/submit", handleSubmit)
-	http.HandleFunc("/api", handleAPI)
-	http.HandleFunc("/", serveStaticFiles)
+	// Create logger
+	logger, err = logging.New(cfg.Logging, os.Stderr)
+	if err != nil {
+		slog.Error("Failed to create logger", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize metrics
+	metricsProvider, err := metrics.NewProvider(cfg.Metrics, logging.NewSDKLogger(logger))
+	if err != nil {
+		logger.Error("Failed to initialize metrics", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize tracing
+	tracingProvider, err := tracing.NewProvider(context.Background(), cfg.Tracing, "temporal-server")
+	if err != nil {
+		logger.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+
+	codecProvider, err := codec.NewProvider(cfg.Codec, metricsProvider.MetricsHandler())
+	if err != nil {
+		logger.Error("Failed to initialize payload codecs", "error", err)
+		os.Exit(1)
+	}
+
+	idPolicies, err = parseWorkflowIDPolicies(cfg.Server)
+	if err != nil {
+		logger.Error("Invalid server configuration", "error", err)
+		os.Exit(1)
+	}
+	recordDir = cfg.Worker.RecordDir
+
+	err = initializeTemporal(cfg, metricsProvider, tracingProvider, codecProvider)
+	if err != nil {
+		logger.Error("Failed to initialize Temporal client", "error", err)
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/submit", handleSubmit)
+	mux.HandleFunc("/api", handleAPI)
+	mux.HandleFunc("GET /api/workflows", handleListWorkflows)
+	mux.HandleFunc("DELETE /api/workflows/{id}", handleCancel)
+	mux.HandleFunc("POST /api/workflows/{id}/terminate", handleTerminate)
+	mux.HandleFunc("POST /api/workflows/{id}/signal/{name}", handleSignal)
+	mux.HandleFunc("GET /api/workflows/{id}/query/{name}", handleQuery)
+	mux.HandleFunc("POST /api/workflows/{id}/update/{name}", handleUpdate)
+	mux.HandleFunc("POST /api/batches", handleStartBatch)
+	mux.HandleFunc("GET /api/batches/{id}", handleGetBatch)
+	mux.HandleFunc("POST /api/schedules", handleCreateSchedule)
+	mux.HandleFunc("GET /api/schedules/{id}", handleDescribeSchedule)
+	mux.HandleFunc("POST /api/schedules/{id}/pause", handlePauseSchedule(true))
+	mux.HandleFunc("POST /api/schedules/{id}/unpause", handlePauseSchedule(false))
+	mux.HandleFunc("POST /api/schedules/{id}/trigger", handleTriggerSchedule)
+	mux.HandleFunc("DELETE /api/schedules/{id}", handleDeleteSchedule)
+	mux.HandleFunc("GET /history/rows", handleHistoryRows)
+	mux.HandleFunc("/", serveStaticFiles)
+
+	// Let the Temporal UI and CLI decode payloads with the same codecs
+	var codecServer *http.Server
+	if cfg.Codec.Server.Enabled {
+		handler, err := codec.NewHTTPHandler(codecProvider, cfg.Codec.Server, cfg.Temporal.Namespace)
+		if err != nil {
+			logger.Error("Invalid codec server configuration", "error", err)
+			os.Exit(1)
+		}
+		if cfg.Codec.Server.AuthToken == "" {
+			logger.Warn("Codec endpoints accept unauthenticated requests; CODEC_SERVER_INSECURE is for local development only")
+		}
+
+		if address := cfg.Codec.Server.ListenAddress; address != "" {
+			codecMux := http.NewServeMux()
+			codecMux.Handle("/codec/", handler)
+			codecServer = &http.Server{Addr: address, Handler: logRequests(codecMux)}
+			go func() {
+				logger.Info("Codec server running", "address", address)
+				if err := codecServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.Error("Codec server failed", "error", err)
+					os.Exit(1)
+				}
+			}()
+		} else {
+			mux.Handle("/codec/", handler)
+		}
+	}
+
+	// Expose metrics on the app server as well as any dedicated listener
+	if handler := metricsProvider.HTTPHandler(); handler != nil {
+		path := cfg.Metrics.Prometheus.HandlerPath
+		if path == "" {
+			path = "/metrics"
+		}
+		mux.Handle(path, handler)
+	}
 
 	port := 4000
-	fmt.Printf("Server running on port %d\n", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", port),
+		Handler: tracingProvider.Middleware(logRequests(mux), "http.server"),
+	}
+
+	// Stop accepting requests on SIGINT/SIGTERM so metrics can be flushed.
+	// done is closed once the requests in flight have finished.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Error("Unable to shut down server", "error", err)
+		}
+		if codecServer != nil {
+			if err := codecServer.Shutdown(shutdownCtx); err != nil {
+				logger.Error("Unable to shut down codec server", "error", err)
+			}
+		}
+	}()
+
+	logger.Info("Server running", "port", port)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Server failed", "error", err)
+		os.Exit(1)
+	}
+	// ListenAndServe returns as soon as Shutdown starts; the handlers still
+	// running need the client and record metrics until Shutdown returns
+	<-done
+
+	temporalClient.Close()
+
+	closeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := metricsProvider.Close(closeCtx); err != nil {
+		logger.Error("Unable to flush metrics", "error", err)
+	}
+	if err := tracingProvider.Shutdown(closeCtx); err != nil {
+		logger.Error("Unable to flush traces", "error", err)
+	}
 }
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/webhook"
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	"github.com/natemollica-nm/temporal/pkg/temporal/workflows/drift"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// defaultScheduleID is the ID of the drift monitor schedule when the
+	// request names none
+	defaultScheduleID = "ip-drift-monitor"
+
+	defaultDriftInterval = 15 * time.Minute
+	// minDriftInterval keeps the monitor from exceeding ip-api's rate limit
+	minDriftInterval = time.Minute
+
+	scheduleIDKey = "ScheduleID"
+)
+
+// driftScheduleSpec returns when the drift monitor runs: every interval, a Go
+// duration, or on a cron expression. Neither means every 15 minutes.
+func driftScheduleSpec(interval, cron string) (client.ScheduleSpec, error) {
+	interval, cron = strings.TrimSpace(interval), strings.TrimSpace(cron)
+	switch {
+	case interval != "" && cron != "":
+		return client.ScheduleSpec{}, errors.New("interval and cron are mutually exclusive")
+	case cron != "":
+		return client.ScheduleSpec{CronExpressions: []string{cron}}, nil
+	}
+
+	every := defaultDriftInterval
+	if interval != "" {
+		var err error
+		if every, err = time.ParseDuration(interval); err != nil {
+			return client.ScheduleSpec{}, fmt.Errorf("invalid interval: %w", err)
+		}
+	}
+	if every < minDriftInterval {
+		return client.ScheduleSpec{}, fmt.Errorf("interval must be at least %s", minDriftInterval)
+	}
+	return client.ScheduleSpec{Intervals: []client.ScheduleIntervalSpec{{Every: every}}}, nil
+}
+
+// validWebhookURL accepts empty and absolute http(s) URLs for the request
+// field named field
+func validWebhookURL(field, s string) error {
+	if s == "" {
+		return nil
+	}
+	if webhook.ValidateURL(s) != nil {
+		return fmt.Errorf("%s must be an absolute http or https URL", field)
+	}
+	return nil
+}
+
+// Create a schedule running IPDriftMonitor
+func handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var requestData struct {
+		ID         string `json:"id"`
+		Interval   string `json:"interval"`
+		Cron       string `json:"cron"`
+		WebhookURL string `json:"webhookUrl"`
+		Paused     bool   `json:"paused"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	spec, err := driftScheduleSpec(requestData.Interval, requestData.Cron)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validWebhookURL("webhookUrl", requestData.WebhookURL); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	scheduleID := strings.TrimSpace(requestData.ID)
+	if scheduleID == "" {
+		scheduleID = defaultScheduleID
+	}
+
+	_, err = temporalClient.ScheduleClient().Create(r.Context(), client.ScheduleOptions{
+		ID:     scheduleID,
+		Spec:   spec,
+		Paused: requestData.Paused,
+		Action: &client.ScheduleWorkflowAction{
+			// The schedule appends the scheduled time to the ID of each run
+			ID:        scheduleID,
+			Workflow:  drift.IPDriftMonitor,
+			Args:      []any{drift.MonitorInput{WebhookURL: requestData.WebhookURL}},
+			TaskQueue: shared.TaskQueueName,
+		},
+	})
+	if errors.Is(err, temporal.ErrScheduleAlreadyRunning) {
+		writeError(w, http.StatusConflict, "Schedule already exists")
+		return
+	}
+	if err != nil {
+		logger.Error("Failed to create schedule", scheduleIDKey, scheduleID, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	logger.Info("Created schedule", scheduleIDKey, scheduleID)
+	writeJSON(w, http.StatusCreated, map[string]string{"scheduleId": scheduleID})
+}
+
+// Describe a schedule: its state, next runs and recent runs
+func handleDescribeSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := r.PathValue("id")
+
+	desc, err := temporalClient.ScheduleClient().GetHandle(r.Context(), scheduleID).Describe(r.Context())
+	if err != nil {
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	type run struct {
+		ScheduledAt time.Time `json:"scheduledAt"`
+		WorkflowID  string    `json:"workflowId,omitempty"`
+		RunID       string    `json:"runId,omitempty"`
+	}
+	var recent []run
+	for _, action := range desc.Info.RecentActions {
+		entry := run{ScheduledAt: action.ScheduleTime}
+		if started := action.StartWorkflowResult; started != nil {
+			entry.WorkflowID, entry.RunID = started.WorkflowID, started.FirstExecutionRunID
+		}
+		recent = append(recent, entry)
+	}
+	response := map[string]any{
+		"scheduleId": scheduleID,
+		"numActions": desc.Info.NumActions,
+		"nextRuns":   desc.Info.NextActionTimes,
+		"recentRuns": recent,
+		"paused":     false,
+	}
+	if state := desc.Schedule.State; state != nil {
+		response["paused"], response["note"] = state.Paused, state.Note
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handlePauseSchedule returns a handler pausing a schedule, or resuming it
+// when pause is false
+func handlePauseSchedule(pause bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheduleID := r.PathValue("id")
+
+		var requestData struct {
+			Note string `json:"note"`
+		}
+		// The note is optional, and so is the body
+		if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		handle := temporalClient.ScheduleClient().GetHandle(r.Context(), scheduleID)
+		var err error
+		if pause {
+			err = handle.Pause(r.Context(), client.SchedulePauseOptions{Note: requestData.Note})
+		} else {
+			err = handle.Unpause(r.Context(), client.ScheduleUnpauseOptions{Note: requestData.Note})
+		}
+		if err != nil {
+			logger.Error("Failed to change schedule state", scheduleIDKey, scheduleID, "paused", pause, "error", err)
+			writeError(w, temporalErrorStatus(err), err.Error())
+			return
+		}
+
+		logger.Info("Changed schedule state", scheduleIDKey, scheduleID, "paused", pause)
+		writeJSON(w, http.StatusOK, map[string]any{"scheduleId": scheduleID, "paused": pause})
+	}
+}
+
+// Run a schedule's action now, in addition to its scheduled runs
+func handleTriggerSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := r.PathValue("id")
+
+	err := temporalClient.ScheduleClient().GetHandle(r.Context(), scheduleID).Trigger(r.Context(), client.ScheduleTriggerOptions{})
+	if err != nil {
+		logger.Error("Failed to trigger schedule", scheduleIDKey, scheduleID, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	logger.Info("Triggered schedule", scheduleIDKey, scheduleID)
+	writeJSON(w, http.StatusAccepted, map[string]string{"scheduleId": scheduleID, "status": "triggered"})
+}
+
+// Delete a schedule. Runs it already started are not affected.
+func handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	scheduleID := r.PathValue("id")
+
+	if err := temporalClient.ScheduleClient().GetHandle(r.Context(), scheduleID).Delete(r.Context()); err != nil {
+		logger.Error("Failed to delete schedule", scheduleIDKey, scheduleID, "error", err)
+		writeError(w, temporalErrorStatus(err), err.Error())
+		return
+	}
+
+	logger.Info("Deleted schedule", scheduleIDKey, scheduleID)
+	writeJSON(w, http.StatusOK, map[string]string{"scheduleId": scheduleID, "status": "deleted"})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDriftScheduleSpec(t *testing.T) {
+	tests := []struct {
+		name      string
+		interval  string
+		cron      string
+		wantEvery time.Duration
+		wantCron  string
+		wantErr   bool
+	}{
+		{name: "default", wantEvery: defaultDriftInterval},
+		{name: "interval", interval: "1h", wantEvery: time.Hour},
+		{name: "cron", cron: "0 * * * *", wantCron: "0 * * * *"},
+		{name: "both", interval: "1h", cron: "0 * * * *", wantErr: true},
+		{name: "too frequent", interval: "10s", wantErr: true},
+		{name: "not a duration", interval: "hourly", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := driftScheduleSpec(tt.interval, tt.cron)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("spec = %+v, want error", spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("driftScheduleSpec: %v", err)
+			}
+
+			if tt.wantCron != "" {
+				if len(spec.CronExpressions) != 1 || spec.CronExpressions[0] != tt.wantCron || len(spec.Intervals) != 0 {
+					t.Errorf("spec = %+v, want cron %q", spec, tt.wantCron)
+				}
+				return
+			}
+			if len(spec.Intervals) != 1 || spec.Intervals[0].Every != tt.wantEvery || len(spec.CronExpressions) != 0 {
+				t.Errorf("spec = %+v, want every %s", spec, tt.wantEvery)
+			}
+		})
+	}
+}
+
+func TestValidWebhookURL(t *testing.T) {
+	for _, s := range []string{"", "https://hooks.example.com/drift", "http://localhost:8080/hook"} {
+		if err := validWebhookURL("webhookUrl", s); err != nil {
+			t.Errorf("validWebhookURL(%q) = %v, want nil", s, err)
+		}
+	}
+	for _, s := range []string{"hooks.example.com/drift", "ftp://example.com", "/drift"} {
+		if err := validWebhookURL("webhookUrl", s); err == nil {
+			t.Errorf("validWebhookURL(%q) succeeded, want error", s)
+		}
+	}
+}
@@ -1,60 +1,172 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"time"
 
+	"github.com/natemollica-nm/temporal/internal/codec"
 	"github.com/natemollica-nm/temporal/internal/config"
+	"github.com/natemollica-nm/temporal/internal/logging"
 	"github.com/natemollica-nm/temporal/internal/metrics"
+	"github.com/natemollica-nm/temporal/internal/tracing"
 	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/store"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/webhook"
 	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
 	"github.com/natemollica-nm/temporal/pkg/temporal/workflows/basic"
+	"github.com/natemollica-nm/temporal/pkg/temporal/workflows/batch"
+	"github.com/natemollica-nm/temporal/pkg/temporal/workflows/drift"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
-
-	sdktally "go.temporal.io/sdk/contrib/tally"
-	sdklog "go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
 )
 
 func main() {
 	// Load configuration
-	cfg := config.Default()
-	
-	// Create logger - use nil for default logger
-	var logger sdklog.Logger
-	
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Create logger
+	slogger, err := logging.New(cfg.Logging, os.Stderr)
+	if err != nil {
+		slog.Error("Failed to create logger", "error", err)
+		os.Exit(1)
+	}
+	logger := logging.NewSDKLogger(slogger)
+
 	// Initialize metrics
-	if err := metrics.Initialize(cfg.Metrics, logger); err != nil {
-		log.Fatalf("Failed to initialize metrics: %v", err)
+	metricsProvider, err := metrics.NewProvider(cfg.Metrics, logger)
+	if err != nil {
+		logger.Error("Failed to initialize metrics", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize tracing
+	tracingProvider, err := tracing.NewProvider(context.Background(), cfg.Tracing, "temporal-worker")
+	if err != nil {
+		logger.Error("Failed to initialize tracing", "error", err)
+		os.Exit(1)
+	}
+	tracingInterceptor, err := tracingProvider.Interceptor()
+	if err != nil {
+		logger.Error("Failed to create tracing interceptor", "error", err)
+		os.Exit(1)
+	}
+
+	// Initialize the payload codecs
+	codecProvider, err := codec.NewProvider(cfg.Codec, metricsProvider.MetricsHandler())
+	if err != nil {
+		logger.Error("Failed to initialize payload codecs", "error", err)
+		os.Exit(1)
 	}
 
-	// Create the Temporal client
+	// Create the Temporal client; the worker inherits its interceptors and
+	// converters
 	c, err := client.Dial(client.Options{
-		HostPort:       cfg.Temporal.HostPort,
-		Namespace:      cfg.Temporal.Namespace,
-		MetricsHandler: sdktally.NewMetricsHandler(metrics.GetScope()),
-		Logger:         logger,
+		HostPort:         cfg.Temporal.HostPort,
+		Namespace:        cfg.Temporal.Namespace,
+		MetricsHandler:   metricsProvider.MetricsHandler(),
+		Logger:           logger,
+		Interceptors:     []interceptor.ClientInterceptor{tracingInterceptor},
+		DataConverter:    codecProvider.DataConverter(),
+		FailureConverter: codecProvider.FailureConverter(),
 	})
 	if err != nil {
-		log.Fatalln("Unable to create Temporal client", err)
+		logger.Error("Unable to create Temporal client", "error", err)
+		os.Exit(1)
 	}
 	defer c.Close()
 
 	// Create the Temporal worker
-	w := worker.New(c, shared.TaskQueueName, worker.Options{})
+	workerOpts, err := workerOptions(cfg.Worker)
+	if err != nil {
+		logger.Error("Invalid worker configuration", "error", err)
+		c.Close()
+		os.Exit(1)
+	}
+	w := worker.New(c, shared.TaskQueueName, workerOpts)
+	logger.Info("Starting worker",
+		"deploymentName", cfg.Worker.DeploymentName,
+		"buildID", cfg.Worker.BuildID,
+		"useVersioning", cfg.Worker.UseVersioning)
 
 	// inject HTTP client into the Activities Struct
+	httpClient := &http.Client{Transport: tracingProvider.Transport(nil)}
 	activities := &ip.IPActivities{
-		HTTPClient: http.DefaultClient,
+		HTTPClient: httpClient,
+		BatchDir:   cfg.Worker.BatchDir,
 	}
+	webhookActivities := &webhook.WebhookActivities{
+		HTTPClient: httpClient,
+		Secret:     []byte(cfg.Worker.WebhookSecret),
+	}
+	storeActivities := &store.StoreActivities{Dir: cfg.Worker.RecordDir}
 
 	// Register Workflow and Activities
 	w.RegisterWorkflow(basic.GetAddressFromIP)
+	w.RegisterWorkflow(basic.PublishAddressFromIP)
+	w.RegisterWorkflow(batch.BatchEnrich)
+	w.RegisterWorkflow(batch.EnrichIP)
+	w.RegisterWorkflow(drift.IPDriftMonitor)
 	w.RegisterActivity(activities)
+	w.RegisterActivity(webhookActivities)
+	w.RegisterActivity(storeActivities)
 
 	// Start the Worker
 	err = w.Run(worker.InterruptCh())
+
+	// Flush (and for prometheus-push, push) the final metrics and spans before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if shutdownErr := metricsProvider.Close(ctx); shutdownErr != nil {
+		logger.Error("Unable to flush metrics", "error", shutdownErr)
+	}
+	if shutdownErr := tracingProvider.Shutdown(ctx); shutdownErr != nil {
+		logger.Error("Unable to flush traces", "error", shutdownErr)
+	}
+
 	if err != nil {
-		log.Fatalln("Unable to start Temporal worker", err)
+		logger.Error("Unable to start Temporal worker", "error", err)
+		c.Close()
+		os.Exit(1)
+	}
+}
+
+// workerOptions translates the worker configuration into worker.Options.
+// Without UseVersioning the deployment version only identifies the build.
+func workerOptions(cfg config.WorkerConfig) (worker.Options, error) {
+	var opts worker.Options
+	if cfg.BuildID != "" {
+		opts.DeploymentOptions.Version = worker.WorkerDeploymentVersion{
+			DeploymentName: cfg.DeploymentName,
+			BuildID:        cfg.BuildID,
+		}
+	}
+	if !cfg.UseVersioning {
+		return opts, nil
+	}
+
+	if cfg.DeploymentName == "" || cfg.BuildID == "" {
+		return opts, errors.New("worker versioning requires a deployment name and build ID")
+	}
+	opts.DeploymentOptions.UseVersioning = true
+
+	switch cfg.DefaultVersioningBehavior {
+	case "pinned":
+		opts.DeploymentOptions.DefaultVersioningBehavior = workflow.VersioningBehaviorPinned
+	case "auto-upgrade":
+		opts.DeploymentOptions.DefaultVersioningBehavior = workflow.VersioningBehaviorAutoUpgrade
+	default:
+		return opts, fmt.Errorf("unknown versioning behavior %q", cfg.DefaultVersioningBehavior)
 	}
+	return opts, nil
 }
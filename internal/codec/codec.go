@@ -0,0 +1,76 @@
+package codec
+
+import (
+	"github.com/natemollica-nm/temporal/internal/config"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Provider builds the payload codec chain of a process from its
+// configuration. The server, the worker and any codec server must use the
+// same chain to read each other's payloads.
+type Provider struct {
+	codecs []converter.PayloadCodec
+	// encodes is false when the codecs only decode payloads written with an
+	// earlier configuration
+	encodes  bool
+	encrypts bool
+}
+
+// NewProvider creates the codecs enabled in cfg. Codecs record their metrics
+// with metricsHandler.
+func NewProvider(cfg config.CodecConfig, metricsHandler client.MetricsHandler) (*Provider, error) {
+	var p Provider
+	// Encryption is on once keys are configured
+	if cfg.Encryption.KeyFile != "" || cfg.Encryption.Keys != "" {
+		keys, err := LoadKeyring(cfg.Encryption)
+		if err != nil {
+			return nil, err
+		}
+		p.codecs = append(p.codecs, &EncryptionCodec{Keys: keys})
+		p.encodes, p.encrypts = true, true
+	}
+	// Encrypted data does not compress, so compression comes after
+	// encryption, which makes it run first on encode. With compression off
+	// the codec still decodes what was compressed while it was on.
+	compression := &CompressionCodec{}
+	if algorithm := cfg.Compression.Algorithm; algorithm != "" && algorithm != "none" {
+		var err error
+		compression, err = NewCompressionCodec(algorithm, cfg.Compression.Threshold, metricsHandler)
+		if err != nil {
+			return nil, err
+		}
+		p.encodes = true
+	}
+	p.codecs = append(p.codecs, compression)
+	return &p, nil
+}
+
+// Codecs returns the codecs in the order a data converter applies them to
+// decoded payloads; encoding runs them last to first.
+func (p *Provider) Codecs() []converter.PayloadCodec {
+	return p.codecs
+}
+
+// Encrypts reports whether new payloads are encrypted. Search attributes
+// never are, so callers leave out the ones that carry personal data.
+func (p *Provider) Encrypts() bool {
+	return p.encrypts
+}
+
+// DataConverter returns the default data converter wrapped in the codecs.
+func (p *Provider) DataConverter() converter.DataConverter {
+	return converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), p.codecs...)
+}
+
+// FailureConverter returns a failure converter whose failure messages and
+// stack traces go through DataConverter, since they can carry the same data
+// as the payloads. They are only moved into encoded attributes while a codec
+// encodes.
+func (p *Provider) FailureConverter() converter.FailureConverter {
+	return temporal.NewDefaultFailureConverter(temporal.DefaultFailureConverterOptions{
+		DataConverter:          p.DataConverter(),
+		EncodeCommonAttributes: p.encodes,
+	})
+}
@@ -0,0 +1,155 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+)
+
+// Encodings of compressed payloads. Their data is the compressed, marshalled
+// original payload.
+const (
+	EncodingGzip = "binary/gzip"
+	EncodingZstd = "binary/zstd"
+)
+
+// maxDecompressedBytes bounds the size a compressed payload may expand to.
+// Temporal rejects payloads far smaller than this, so anything larger is
+// corrupt or malicious.
+const maxDecompressedBytes = 64 << 20
+
+var (
+	// Shared, since EncodeAll and DecodeAll are safe for concurrent use
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedBytes))
+)
+
+// CompressionCodec compresses payloads of more than Threshold bytes with gzip
+// or zstd. A payload that does not shrink is left as it is. Decode handles
+// both algorithms, whichever one encodes, and passes other payloads through.
+// Without an Algorithm the codec only decodes, so payloads compressed before
+// compression was turned off stay readable.
+type CompressionCodec struct {
+	Algorithm string // "gzip", "zstd" or empty to never compress
+	Threshold int
+	// Metrics receives the payload sizes before and after compression; nil
+	// discards them
+	Metrics client.MetricsHandler
+}
+
+var _ converter.PayloadCodec = (*CompressionCodec)(nil)
+
+// NewCompressionCodec validates algorithm and threshold.
+func NewCompressionCodec(algorithm string, threshold int, metrics client.MetricsHandler) (*CompressionCodec, error) {
+	if algorithm != "gzip" && algorithm != "zstd" {
+		return nil, fmt.Errorf("unknown compression algorithm %q, want gzip, zstd or none", algorithm)
+	}
+	if threshold < 0 {
+		return nil, fmt.Errorf("compression threshold %d is negative", threshold)
+	}
+	return &CompressionCodec{Algorithm: algorithm, Threshold: threshold, Metrics: metrics}, nil
+}
+
+// Encode compresses the payloads above the threshold.
+func (c *CompressionCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	if c.Algorithm == "" {
+		return payloads, nil
+	}
+	metrics := c.Metrics
+	if metrics == nil {
+		metrics = client.MetricsNopHandler
+	}
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		result[i] = p
+		size := p.Size()
+		if size > c.Threshold {
+			compressed, err := c.compress(p)
+			if err != nil {
+				return nil, err
+			}
+			if compressed.Size() < size {
+				result[i] = compressed
+			}
+		}
+		shared.RecordPayloadCompression(metrics, c.Algorithm, result[i] != p, size, result[i].Size())
+	}
+	return result, nil
+}
+
+func (c *CompressionCodec) compress(p *commonpb.Payload) (*commonpb.Payload, error) {
+	data, err := p.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := EncodingZstd
+	if c.Algorithm == "gzip" {
+		encoding = EncodingGzip
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		data = buf.Bytes()
+	} else {
+		data = zstdEncoder.EncodeAll(data, nil)
+	}
+	return &commonpb.Payload{
+		Metadata: map[string][]byte{converter.MetadataEncoding: []byte(encoding)},
+		Data:     data,
+	}, nil
+}
+
+// Decode decompresses the compressed payloads.
+func (c *CompressionCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		var data []byte
+		var err error
+		switch encoding := string(p.GetMetadata()[converter.MetadataEncoding]); encoding {
+		case EncodingGzip:
+			data, err = gunzip(p.GetData())
+		case EncodingZstd:
+			data, err = zstdDecoder.DecodeAll(p.GetData(), nil)
+		default:
+			result[i] = p
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		result[i] = &commonpb.Payload{}
+		if err := result[i].Unmarshal(data); err != nil {
+			return nil, fmt.Errorf("failed to decode decompressed payload: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxDecompressedBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxDecompressedBytes {
+		return nil, errors.New("decompressed payload is too large")
+	}
+	return out, nil
+}
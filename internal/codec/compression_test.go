@@ -0,0 +1,182 @@
+package codec
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	"github.com/natemollica-nm/temporal/internal/metrics"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// batchResult stands in for a large, repetitive BatchEnrich result
+var batchResult = strings.Repeat(`{"ip":"203.0.113.7","location":"Ashburn, Virginia, United States","isp":"Example Networks"},`, 200)
+
+func TestCompressionCodec(t *testing.T) {
+	for _, tt := range []struct {
+		algorithm    string
+		wantEncoding string
+	}{
+		{algorithm: "gzip", wantEncoding: EncodingGzip},
+		{algorithm: "zstd", wantEncoding: EncodingZstd},
+	} {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			reporter := metrics.NewMemoryReporter()
+			codec, err := NewCompressionCodec(tt.algorithm, 1024, reporter.MetricsHandler())
+			if err != nil {
+				t.Fatalf("NewCompressionCodec: %v", err)
+			}
+			dc := converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), codec)
+
+			original, _ := converter.GetDefaultDataConverter().ToPayload(batchResult)
+			payload, err := dc.ToPayload(batchResult)
+			if err != nil {
+				t.Fatalf("ToPayload: %v", err)
+			}
+			if got := string(payload.GetMetadata()[converter.MetadataEncoding]); got != tt.wantEncoding {
+				t.Errorf("encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if payload.Size() >= original.Size()/10 {
+				t.Errorf("compressed %d bytes to %d", original.Size(), payload.Size())
+			}
+			var got string
+			if err := dc.FromPayload(payload, &got); err != nil || got != batchResult {
+				t.Fatalf("FromPayload = %d bytes, %v", len(got), err)
+			}
+
+			tags := map[string]string{"algorithm": tt.algorithm, "compressed": "true"}
+			reporter.AssertCounter(t, "payload_compression_bytes_before", tags, int64(original.Size()))
+			reporter.AssertCounter(t, "payload_compression_bytes_after", tags, int64(payload.Size()))
+		})
+	}
+}
+
+func TestCompressionCodecSkips(t *testing.T) {
+	random := make([]byte, 4096)
+	rand.Read(random)
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{name: "below threshold", value: "203.0.113.7"},
+		{name: "incompressible", value: random},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := metrics.NewMemoryReporter()
+			codec, err := NewCompressionCodec("zstd", 1024, reporter.MetricsHandler())
+			if err != nil {
+				t.Fatalf("NewCompressionCodec: %v", err)
+			}
+			original, err := converter.GetDefaultDataConverter().ToPayload(tt.value)
+			if err != nil {
+				t.Fatalf("ToPayload: %v", err)
+			}
+
+			encoded, err := codec.Encode([]*commonpb.Payload{original})
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if encoded[0] != original {
+				t.Errorf("payload was replaced with %q encoding", encoded[0].GetMetadata()[converter.MetadataEncoding])
+			}
+			tags := map[string]string{"algorithm": "zstd", "compressed": "false"}
+			reporter.AssertCounter(t, "payload_compression_bytes_before", tags, int64(original.Size()))
+			reporter.AssertCounter(t, "payload_compression_bytes_after", tags, int64(original.Size()))
+		})
+	}
+}
+
+func TestCompressionCodecSwitchAlgorithm(t *testing.T) {
+	gzipCodec, _ := NewCompressionCodec("gzip", 0, nil)
+	zstdCodec, _ := NewCompressionCodec("zstd", 0, nil)
+
+	// Payloads compressed before the switch still decode
+	payload, _ := converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), gzipCodec).ToPayload(batchResult)
+	var got string
+	dc := converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), zstdCodec)
+	if err := dc.FromPayload(payload, &got); err != nil || got != batchResult {
+		t.Errorf("decode gzip payload with zstd codec = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestProviderDecodesWithCompressionOff(t *testing.T) {
+	for _, algorithm := range []string{"gzip", "zstd"} {
+		t.Run(algorithm, func(t *testing.T) {
+			on, err := NewProvider(config.CodecConfig{Compression: config.CompressionConfig{Algorithm: algorithm}}, nil)
+			if err != nil {
+				t.Fatalf("NewProvider: %v", err)
+			}
+			payload, err := on.DataConverter().ToPayload(batchResult)
+			if err != nil {
+				t.Fatalf("ToPayload: %v", err)
+			}
+
+			// Histories written while compression was on stay readable
+			off, err := NewProvider(config.CodecConfig{Compression: config.CompressionConfig{Algorithm: "none"}}, nil)
+			if err != nil {
+				t.Fatalf("NewProvider: %v", err)
+			}
+			if on.Encrypts() || off.Encrypts() {
+				t.Error("Encrypts = true without keys")
+			}
+			var got string
+			if err := off.DataConverter().FromPayload(payload, &got); err != nil || got != batchResult {
+				t.Errorf("FromPayload = %d bytes, %v", len(got), err)
+			}
+			encoded, err := off.DataConverter().ToPayload(batchResult)
+			if err != nil {
+				t.Fatalf("ToPayload: %v", err)
+			}
+			if got := string(encoded.GetMetadata()[converter.MetadataEncoding]); got != converter.MetadataEncodingJSON {
+				t.Errorf("encoding with compression off = %q, want %q", got, converter.MetadataEncodingJSON)
+			}
+		})
+	}
+}
+
+func TestProviderCompressesBeforeEncrypting(t *testing.T) {
+	p, err := NewProvider(config.CodecConfig{
+		Compression: config.CompressionConfig{Algorithm: "zstd", Threshold: 1024},
+		Encryption:  config.EncryptionConfig{Keys: entry("k1", oldKey)},
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	if !p.Encrypts() {
+		t.Error("Encrypts = false with keys configured")
+	}
+
+	payload, err := p.DataConverter().ToPayload(batchResult)
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+	if got := string(payload.GetMetadata()[converter.MetadataEncoding]); got != EncodingEncrypted {
+		t.Fatalf("outer encoding = %q, want %q", got, EncodingEncrypted)
+	}
+	decrypted, err := p.Codecs()[0].Decode([]*commonpb.Payload{payload})
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got := string(decrypted[0].GetMetadata()[converter.MetadataEncoding]); got != EncodingZstd {
+		t.Errorf("inner encoding = %q, want %q", got, EncodingZstd)
+	}
+
+	var got string
+	if err := p.DataConverter().FromPayload(payload, &got); err != nil || got != batchResult {
+		t.Errorf("FromPayload = %d bytes, %v", len(got), err)
+	}
+}
+
+func TestNewCompressionCodecErrors(t *testing.T) {
+	if _, err := NewProvider(config.CodecConfig{Compression: config.CompressionConfig{Algorithm: "brotli"}}, nil); err == nil {
+		t.Error("NewProvider accepted an unknown algorithm")
+	}
+	if _, err := NewCompressionCodec("gzip", -1, nil); err == nil {
+		t.Error("NewCompressionCodec accepted a negative threshold")
+	}
+}
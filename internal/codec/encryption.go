@@ -0,0 +1,188 @@
+package codec
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// Metadata of encrypted payloads
+const (
+	// EncodingEncrypted is the encoding of encrypted payloads. Their data is
+	// the nonce followed by the sealed, marshalled original payload.
+	EncodingEncrypted = "binary/encrypted"
+	// MetadataEncryptionKeyID names the key that encrypted a payload
+	MetadataEncryptionKeyID = "encryption-key-id"
+)
+
+// keySize is the key length of AES-256
+const keySize = 32
+
+// Keyring holds the keys payloads are encrypted with, by ID. The active key
+// encrypts new payloads; every key decrypts the payloads that name it, so
+// keys can be rotated without losing access to existing histories.
+type Keyring struct {
+	active string
+	aeads  map[string]cipher.AEAD
+}
+
+// NewKeyring validates keys, which must be 32 bytes each, and activeID,
+// which must name one of them.
+func NewKeyring(keys map[string][]byte, activeID string) (*Keyring, error) {
+	if _, ok := keys[activeID]; !ok {
+		return nil, fmt.Errorf("active encryption key %q is not in the keyring", activeID)
+	}
+	k := &Keyring{active: activeID, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("encryption key %q is %d bytes, want %d", id, len(key), keySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// LoadKeyring reads the keys of cfg from its key file and inline keys, both
+// holding "<key ID>=<base64 key>" entries. A key ID found in both must hold
+// the same key. Without an active key ID, a single key is active.
+func LoadKeyring(cfg config.EncryptionConfig) (*Keyring, error) {
+	keys := map[string][]byte{}
+	add := func(entry, source string) error {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			return nil
+		}
+		id, encoded, ok := strings.Cut(entry, "=")
+		id, encoded = strings.TrimSpace(id), strings.TrimSpace(encoded)
+		if !ok || id == "" || encoded == "" {
+			return fmt.Errorf("%s: want <key ID>=<base64 key>", source)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("%s: key %q is not valid base64", source, id)
+		}
+		if existing, ok := keys[id]; ok && string(existing) != string(key) {
+			return fmt.Errorf("%s: key %q is defined twice with different values", source, id)
+		}
+		keys[id] = key
+		return nil
+	}
+
+	if cfg.KeyFile != "" {
+		f, err := os.Open(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open encryption key file: %w", err)
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for line := 1; scanner.Scan(); line++ {
+			if err := add(scanner.Text(), fmt.Sprintf("%s:%d", cfg.KeyFile, line)); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+		}
+	}
+	for _, entry := range strings.Split(cfg.Keys, ",") {
+		if err := add(entry, "inline encryption keys"); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption keys configured")
+	}
+	activeID := cfg.ActiveKeyID
+	if activeID == "" {
+		if len(keys) > 1 {
+			return nil, errors.New("an active encryption key ID is required with more than one key")
+		}
+		for id := range keys {
+			activeID = id
+		}
+	}
+	return NewKeyring(keys, activeID)
+}
+
+// EncryptionCodec encrypts payloads with AES-256-GCM. Payloads that are not
+// encrypted pass Decode unchanged, so histories written before encryption was
+// enabled stay readable.
+type EncryptionCodec struct {
+	Keys *Keyring
+}
+
+var _ converter.PayloadCodec = (*EncryptionCodec)(nil)
+
+// Encode encrypts each payload with the active key.
+func (c *EncryptionCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	aead := c.Keys.aeads[c.Keys.active]
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		plaintext, err := p.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{
+				converter.MetadataEncoding: []byte(EncodingEncrypted),
+				MetadataEncryptionKeyID:    []byte(c.Keys.active),
+			},
+			// The key ID is authenticated, so it cannot be swapped
+			Data: aead.Seal(nonce, nonce, plaintext, []byte(c.Keys.active)),
+		}
+	}
+	return result, nil
+}
+
+// Decode decrypts the encrypted payloads with the key they name.
+func (c *EncryptionCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.GetMetadata()[converter.MetadataEncoding]) != EncodingEncrypted {
+			result[i] = p
+			continue
+		}
+		keyID := string(p.GetMetadata()[MetadataEncryptionKeyID])
+		aead, ok := c.Keys.aeads[keyID]
+		if !ok {
+			return nil, fmt.Errorf("payload encrypted with unknown key %q", keyID)
+		}
+		data := p.GetData()
+		if len(data) < aead.NonceSize() {
+			return nil, errors.New("encrypted payload is too short")
+		}
+		plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(keyID))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt payload with key %q: %w", keyID, err)
+		}
+		result[i] = &commonpb.Payload{}
+		if err := result[i].Unmarshal(plaintext); err != nil {
+			return nil, fmt.Errorf("failed to decode decrypted payload: %w", err)
+		}
+	}
+	return result, nil
+}
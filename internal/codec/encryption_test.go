@@ -0,0 +1,182 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	"go.temporal.io/sdk/converter"
+)
+
+var (
+	oldKey = bytes.Repeat([]byte{1}, keySize)
+	newKey = bytes.Repeat([]byte{2}, keySize)
+)
+
+func entry(id string, key []byte) string {
+	return id + "=" + base64.StdEncoding.EncodeToString(key)
+}
+
+func newConverter(t *testing.T, keys map[string][]byte, activeID string) converter.DataConverter {
+	t.Helper()
+
+	keyring, err := NewKeyring(keys, activeID)
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	return converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), &EncryptionCodec{Keys: keyring})
+}
+
+func TestEncryptionCodecRoundTrip(t *testing.T) {
+	dc := newConverter(t, map[string][]byte{"2026-01": oldKey}, "2026-01")
+
+	payload, err := dc.ToPayload("Hello, Ada. Your IP is 203.0.113.7")
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+	if got := string(payload.GetMetadata()[converter.MetadataEncoding]); got != EncodingEncrypted {
+		t.Errorf("encoding = %q, want %q", got, EncodingEncrypted)
+	}
+	if got := string(payload.GetMetadata()[MetadataEncryptionKeyID]); got != "2026-01" {
+		t.Errorf("key ID = %q, want 2026-01", got)
+	}
+	if bytes.Contains(payload.GetData(), []byte("203.0.113.7")) {
+		t.Error("encrypted payload contains the plaintext")
+	}
+
+	var got string
+	if err := dc.FromPayload(payload, &got); err != nil {
+		t.Fatalf("FromPayload: %v", err)
+	}
+	if got != "Hello, Ada. Your IP is 203.0.113.7" {
+		t.Errorf("decoded %q", got)
+	}
+}
+
+func TestEncryptionCodecRotation(t *testing.T) {
+	before := newConverter(t, map[string][]byte{"old": oldKey}, "old")
+	payload, err := before.ToPayload("203.0.113.7")
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+
+	// After rotating, new payloads use the new key and old ones still decode
+	after := newConverter(t, map[string][]byte{"old": oldKey, "new": newKey}, "new")
+	var got string
+	if err := after.FromPayload(payload, &got); err != nil || got != "203.0.113.7" {
+		t.Fatalf("decode payload of the old key = %q, %v", got, err)
+	}
+	rotated, err := after.ToPayload("203.0.113.7")
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+	if id := string(rotated.GetMetadata()[MetadataEncryptionKeyID]); id != "new" {
+		t.Errorf("key ID = %q, want new", id)
+	}
+
+	// Dropping the old key too early loses its payloads
+	newOnly := newConverter(t, map[string][]byte{"new": newKey}, "new")
+	if err := newOnly.FromPayload(payload, &got); err == nil || !strings.Contains(err.Error(), `unknown key "old"`) {
+		t.Errorf("decode without the old key: %v, want unknown key error", err)
+	}
+}
+
+func TestEncryptionCodecTampering(t *testing.T) {
+	dc := newConverter(t, map[string][]byte{"a": oldKey, "b": oldKey}, "a")
+	var got string
+
+	payload, _ := dc.ToPayload("203.0.113.7")
+	payload.Data[len(payload.Data)-1] ^= 1
+	if err := dc.FromPayload(payload, &got); err == nil {
+		t.Error("decoded a modified payload")
+	}
+
+	// The key ID is authenticated even where two IDs hold the same key
+	payload, _ = dc.ToPayload("203.0.113.7")
+	payload.Metadata[MetadataEncryptionKeyID] = []byte("b")
+	if err := dc.FromPayload(payload, &got); err == nil {
+		t.Error("decoded a payload with a swapped key ID")
+	}
+}
+
+func TestEncryptionCodecPlaintext(t *testing.T) {
+	// Histories written before encryption was enabled stay readable
+	payload, err := converter.GetDefaultDataConverter().ToPayload("203.0.113.7")
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+	var got string
+	dc := newConverter(t, map[string][]byte{"a": oldKey}, "a")
+	if err := dc.FromPayload(payload, &got); err != nil || got != "203.0.113.7" {
+		t.Errorf("decode plaintext payload = %q, %v", got, err)
+	}
+}
+
+func TestLoadKeyring(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	content := "# rotated 2026-10\n" + entry("old", oldKey) + "\n\n" + entry("new", newKey) + "\n"
+	if err := os.WriteFile(keyFile, []byte(content), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		cfg        config.EncryptionConfig
+		wantActive string
+		wantErr    string
+	}{
+		{name: "file", cfg: config.EncryptionConfig{KeyFile: keyFile, ActiveKeyID: "new"}, wantActive: "new"},
+		{name: "inline", cfg: config.EncryptionConfig{Keys: entry("old", oldKey) + ", " + entry("new", newKey), ActiveKeyID: "old"}, wantActive: "old"},
+		{name: "single key is active", cfg: config.EncryptionConfig{Keys: entry("only", oldKey)}, wantActive: "only"},
+		{name: "file and inline agree", cfg: config.EncryptionConfig{KeyFile: keyFile, Keys: entry("new", newKey), ActiveKeyID: "new"}, wantActive: "new"},
+		{name: "file and inline disagree", cfg: config.EncryptionConfig{KeyFile: keyFile, Keys: entry("new", oldKey), ActiveKeyID: "new"}, wantErr: "defined twice"},
+		{name: "several keys without active", cfg: config.EncryptionConfig{KeyFile: keyFile}, wantErr: "active encryption key ID is required"},
+		{name: "unknown active key", cfg: config.EncryptionConfig{KeyFile: keyFile, ActiveKeyID: "next"}, wantErr: "not in the keyring"},
+		{name: "short key", cfg: config.EncryptionConfig{Keys: entry("short", oldKey[:16])}, wantErr: "16 bytes"},
+		{name: "not base64", cfg: config.EncryptionConfig{Keys: "a=not base64!"}, wantErr: "not valid base64"},
+		{name: "missing ID", cfg: config.EncryptionConfig{Keys: base64.StdEncoding.EncodeToString(oldKey)}, wantErr: "<key ID>=<base64 key>"},
+		{name: "missing file", cfg: config.EncryptionConfig{KeyFile: keyFile + ".missing"}, wantErr: "failed to open"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := LoadKeyring(tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadKeyring: %v", err)
+			}
+			if keyring.active != tt.wantActive {
+				t.Errorf("active key = %q, want %q", keyring.active, tt.wantActive)
+			}
+		})
+	}
+}
+
+func TestProviderWithoutKeys(t *testing.T) {
+	p, err := NewProvider(config.CodecConfig{}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	if p.encodes {
+		t.Errorf("codecs = %v, want them to only decode", p.Codecs())
+	}
+	// Payloads are written as the default data converter writes them
+	payload, err := p.DataConverter().ToPayload(batchResult)
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+	want, _ := converter.GetDefaultDataConverter().ToPayload(batchResult)
+	encoding := string(payload.GetMetadata()[converter.MetadataEncoding])
+	if encoding != converter.MetadataEncodingJSON || !bytes.Equal(payload.GetData(), want.GetData()) {
+		t.Errorf("payload = %s %q, want the default encoding", encoding, payload.GetData())
+	}
+}
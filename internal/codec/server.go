@@ -0,0 +1,64 @@
+package codec
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	"go.temporal.io/sdk/converter"
+)
+
+// maxCodecRequestBytes bounds the payloads of one request, which the UI sends
+// a page of history at a time.
+const maxCodecRequestBytes = 32 << 20
+
+// NewHTTPHandler serves the remote codec protocol the Temporal UI and CLI use
+// to show payloads: POST <path>/encode and <path>/decode run the payloads in
+// the body through the codecs of p. Browsers on cfg.Origins may call it
+// cross-origin. Requests must carry cfg.AuthToken as a bearer token; without
+// one, NewHTTPHandler fails unless cfg.Insecure allows serving anyone.
+// Requests for a namespace other than namespace are refused, since the keys
+// belong to it.
+func NewHTTPHandler(p *Provider, cfg config.CodecServerConfig, namespace string) (http.Handler, error) {
+	if cfg.AuthToken == "" && !cfg.Insecure {
+		return nil, errors.New("the codec server requires an auth token; set insecure to serve without one in local development")
+	}
+	codecHandler := converter.NewPayloadCodecHTTPHandler(p.Codecs()...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); origin != "" && slices.Contains(cfg.Origins, origin) {
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		// Preflight requests carry no credentials
+		if r.Method == http.MethodOptions {
+			header.Set("Access-Control-Allow-Methods", "POST")
+			header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Namespace")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if cfg.AuthToken != "" && !hasBearerToken(r, cfg.AuthToken) {
+			header.Set("WWW-Authenticate", `Bearer realm="codec"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if ns := r.Header.Get("X-Namespace"); ns != "" && ns != namespace {
+			http.Error(w, fmt.Sprintf("Namespace %q is not served", ns), http.StatusForbidden)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxCodecRequestBytes)
+		codecHandler.ServeHTTP(w, r)
+	}), nil
+}
+
+func hasBearerToken(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
@@ -0,0 +1,161 @@
+package codec
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	"go.temporal.io/sdk/converter"
+)
+
+const testUIOrigin = "http://localhost:8233"
+
+func newCodecServer(t *testing.T, authToken string) (*httptest.Server, *Provider) {
+	t.Helper()
+
+	p, err := NewProvider(config.CodecConfig{Encryption: config.EncryptionConfig{Keys: entry("k1", oldKey)}}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	cfg := config.CodecServerConfig{Enabled: true, Origins: []string{testUIOrigin}, AuthToken: authToken, Insecure: authToken == ""}
+	handler, err := NewHTTPHandler(p, cfg, "default")
+	if err != nil {
+		t.Fatalf("NewHTTPHandler: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/codec/", handler)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, p
+}
+
+// remoteConverter decodes payloads through the codec server, like the CLI
+func remoteConverter(endpoint string, header http.Header) converter.DataConverter {
+	return converter.NewRemoteDataConverter(converter.GetDefaultDataConverter(), converter.RemoteDataConverterOptions{
+		Endpoint: endpoint,
+		ModifyRequest: func(r *http.Request) error {
+			for key, values := range header {
+				r.Header[key] = values
+			}
+			return nil
+		},
+	})
+}
+
+func TestCodecServerDecode(t *testing.T) {
+	server, p := newCodecServer(t, "s3cret")
+	payload, err := p.DataConverter().ToPayload("203.0.113.7")
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+
+	dc := remoteConverter(server.URL+"/codec", http.Header{
+		"Authorization": {"Bearer s3cret"},
+		"X-Namespace":   {"default"},
+	})
+	var got string
+	if err := dc.FromPayload(payload, &got); err != nil || got != "203.0.113.7" {
+		t.Fatalf("decode through the codec server = %q, %v", got, err)
+	}
+
+	// Encoding through the server produces payloads the worker can read
+	encoded, err := dc.ToPayload("198.51.100.4")
+	if err != nil {
+		t.Fatalf("encode through the codec server: %v", err)
+	}
+	if string(encoded.GetMetadata()[converter.MetadataEncoding]) != EncodingEncrypted {
+		t.Errorf("encoded payload metadata = %v, want it encrypted", encoded.GetMetadata())
+	}
+	if err := p.DataConverter().FromPayload(encoded, &got); err != nil || got != "198.51.100.4" {
+		t.Errorf("decode locally = %q, %v", got, err)
+	}
+}
+
+func TestCodecServerRejects(t *testing.T) {
+	server, p := newCodecServer(t, "s3cret")
+	payload, err := p.DataConverter().ToPayload("203.0.113.7")
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr string
+	}{
+		{name: "no token", header: http.Header{}, wantErr: "Unauthorized"},
+		{name: "wrong token", header: http.Header{"Authorization": {"Bearer guess"}}, wantErr: "Unauthorized"},
+		{name: "other namespace", header: http.Header{"Authorization": {"Bearer s3cret"}, "X-Namespace": {"payments"}}, wantErr: "Forbidden"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			err := remoteConverter(server.URL+"/codec", tt.header).FromPayload(payload, &got)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCodecServerWithoutToken(t *testing.T) {
+	p, err := NewProvider(config.CodecConfig{Encryption: config.EncryptionConfig{Keys: entry("k1", oldKey)}}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	if _, err := NewHTTPHandler(p, config.CodecServerConfig{Enabled: true}, "default"); err == nil {
+		t.Fatal("NewHTTPHandler succeeded without a token or the insecure opt-in")
+	}
+
+	// With the opt-in, any request is served
+	server, p := newCodecServer(t, "")
+	payload, err := p.DataConverter().ToPayload("203.0.113.7")
+	if err != nil {
+		t.Fatalf("ToPayload: %v", err)
+	}
+	var got string
+	if err := remoteConverter(server.URL+"/codec", http.Header{}).FromPayload(payload, &got); err != nil || got != "203.0.113.7" {
+		t.Errorf("decode without a token = %q, %v", got, err)
+	}
+}
+
+func TestCodecServerCORS(t *testing.T) {
+	server, _ := newCodecServer(t, "s3cret")
+
+	tests := []struct {
+		name       string
+		origin     string
+		wantOrigin string
+	}{
+		{name: "Temporal UI", origin: testUIOrigin, wantOrigin: testUIOrigin},
+		{name: "other origin", origin: "https://evil.example.com", wantOrigin: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Preflight requests succeed without the token
+			req, _ := http.NewRequest(http.MethodOptions, server.URL+"/codec/decode", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "authorization,content-type,x-namespace")
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("preflight: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != http.StatusNoContent {
+				t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := resp.Header.Get("Access-Control-Allow-Headers"); !strings.Contains(got, "X-Namespace") {
+				t.Errorf("Access-Control-Allow-Headers = %q, want X-Namespace allowed", got)
+			}
+		})
+	}
+}
@@ -1,49 +1,232 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Temporal TemporalConfig
-	Server   ServerConfig
-	Metrics  MetricsConfig
+	Temporal TemporalConfig `yaml:"temporal"`
+	Server   ServerConfig   `yaml:"server"`
+	Worker   WorkerConfig   `yaml:"worker"`
+	Metrics  MetricsConfig  `yaml:"metrics"`
+	Logging  LoggingConfig  `yaml:"logging"`
+	Tracing  TracingConfig  `yaml:"tracing"`
+	Codec    CodecConfig    `yaml:"codec"`
 }
 
 type TemporalConfig struct {
-	HostPort  string
-	Namespace string
+	HostPort  string `yaml:"hostPort"`
+	Namespace string `yaml:"namespace"`
 }
 
 type ServerConfig struct {
-	Port         int
-	ReadTimeout  time.Duration
-	WriteTimeout time.Duration
+	Port         int           `yaml:"port"`
+	ReadTimeout  time.Duration `yaml:"readTimeout"`
+	WriteTimeout time.Duration `yaml:"writeTimeout"`
+
+	// Policies for submissions carrying an Idempotency-Key or explicit ID
+	// that match an existing execution.
+	WorkflowIDReusePolicy    string `yaml:"workflowIDReusePolicy"`    // "allow-duplicate", "allow-duplicate-failed-only" or "reject-duplicate"
+	WorkflowIDConflictPolicy string `yaml:"workflowIDConflictPolicy"` // "fail", "use-existing" or "terminate-existing"
+}
+
+// WorkerConfig identifies the worker's code version. With UseVersioning the
+// worker joins Worker Versioning and only receives tasks of workflows routed to
+// its deployment version, so old and new builds can run side by side.
+type WorkerConfig struct {
+	DeploymentName            string `yaml:"deploymentName"`
+	BuildID                   string `yaml:"buildID"`
+	UseVersioning             bool   `yaml:"useVersioning"`
+	DefaultVersioningBehavior string `yaml:"defaultVersioningBehavior"` // "pinned" or "auto-upgrade"
+
+	// BatchDir is the directory BatchEnrich may read IP files from; empty
+	// disables file references.
+	BatchDir string `yaml:"batchDir"`
+	// WebhookSecret signs outbound webhook notifications; empty sends them
+	// unsigned.
+	WebhookSecret string `yaml:"webhookSecret"`
+	// RecordDir is the directory PublishAddressFromIP and BatchEnrich store
+	// their records in; empty disables the record store. The server reads
+	// batch results from it, so it must see the same directory.
+	RecordDir string `yaml:"recordDir"`
+}
+
+type LoggingConfig struct {
+	Level  string `yaml:"level"`  // "debug", "info", "warn" or "error"
+	Format string `yaml:"format"` // "text" or "json"
+}
+
+type TracingConfig struct {
+	Exporter    string     `yaml:"exporter"`    // "otlp", "stdout" or "none"
+	ServiceName string     `yaml:"serviceName"` // defaults to the binary name
+	SampleRatio float64    `yaml:"sampleRatio"` // fraction of new traces sampled
+	OTLP        OTLPConfig `yaml:"otlp"`
+}
+
+type OTLPConfig struct {
+	Endpoint string            `yaml:"endpoint"` // host:port of the collector
+	Protocol string            `yaml:"protocol"` // "grpc" or "http"
+	Insecure bool              `yaml:"insecure"`
+	Headers  map[string]string `yaml:"headers"`
+}
+
+// CodecConfig configures the codecs payloads pass through on their way to and
+// from the Temporal server. The server and the worker need the same settings.
+type CodecConfig struct {
+	Compression CompressionConfig `yaml:"compression"`
+	Encryption  EncryptionConfig  `yaml:"encryption"`
+	Server      CodecServerConfig `yaml:"server"`
+}
+
+// CompressionConfig compresses the payloads larger than Threshold bytes.
+// Compressed payloads decode with either algorithm, and still decode with
+// compression off, so it can be changed or turned off at any time.
+type CompressionConfig struct {
+	Algorithm string `yaml:"algorithm"` // "gzip", "zstd" or "none"
+	Threshold int    `yaml:"threshold"` // smaller payloads are left as they are
+}
+
+// EncryptionConfig holds the AES-256 keys payloads are encrypted with. Keys
+// are "<key ID>=<base64 key>" entries; configuring any turns encryption on.
+type EncryptionConfig struct {
+	KeyFile     string `yaml:"keyFile"`     // one entry per line; # starts a comment
+	Keys        string `yaml:"keys"`        // comma-separated entries
+	ActiveKeyID string `yaml:"activeKeyID"` // encrypts new payloads; optional with a single key
+}
+
+// CodecServerConfig exposes the codecs to the Temporal UI and CLI through the
+// remote codec protocol, so they can show decoded payloads. It decrypts for
+// anyone it serves, so it refuses to start without AuthToken unless Insecure
+// is set.
+type CodecServerConfig struct {
+	Enabled bool     `yaml:"enabled"`
+	Origins []string `yaml:"origins"` // browser origins allowed to call it, e.g. the Temporal UI
+	// ListenAddress serves the endpoints on a listener of their own, e.g.
+	// one bound to localhost; empty mounts them on the web server
+	ListenAddress string `yaml:"listenAddress"`
+	AuthToken     string `yaml:"authToken"` // required as a bearer token
+	// Insecure serves without AuthToken, for local development against a
+	// Temporal UI that cannot send one
+	Insecure bool `yaml:"insecure"`
 }
 
 type MetricsConfig struct {
-	Provider   string                 `yaml:"provider"`   // "prometheus" or "dogstatsd"
-	Prometheus PrometheusConfig       `yaml:"prometheus"`
-	DogStatsD  DogStatsDConfig        `yaml:"dogstatsd"`
+	Provider    string            `yaml:"provider"` // "prometheus", "prometheus-push" or "dogstatsd"
+	Prometheus  PrometheusConfig  `yaml:"prometheus"`
+	Pushgateway PushgatewayConfig `yaml:"pushgateway"`
+	DogStatsD   DogStatsDConfig   `yaml:"dogstatsd"`
+	Cardinality CardinalityConfig `yaml:"cardinality"`
 }
 
 type PrometheusConfig struct {
-	ListenAddress string `yaml:"listenAddress"`
+	ListenAddress string `yaml:"listenAddress"` // dedicated scrape listener; empty to only mount on the app server
+	HandlerPath   string `yaml:"handlerPath"`
+
+	GoCollector      bool `yaml:"goCollector"`
+	ProcessCollector bool `yaml:"processCollector"`
+	OpenMetrics      bool `yaml:"openMetrics"` // serve the OpenMetrics format; no exemplars are attached
+
+	TimerType         string              `yaml:"timerType"` // "histogram" or "summary"
+	HistogramBuckets  []float64           `yaml:"histogramBuckets"`
+	SummaryObjectives map[float64]float64 `yaml:"summaryObjectives"`
+
+	// Metrics overrides the timer settings above for individual metrics, keyed
+	// by the exposed metric name.
+	Metrics map[string]PrometheusMetricConfig `yaml:"metrics"`
+}
+
+type PrometheusMetricConfig struct {
+	TimerType         string              `yaml:"timerType"`
+	HistogramBuckets  []float64           `yaml:"histogramBuckets"`
+	SummaryObjectives map[float64]float64 `yaml:"summaryObjectives"`
+}
+
+// PushgatewayConfig configures the "prometheus-push" provider, which pushes
+// the Prometheus registry instead of waiting to be scraped.
+type PushgatewayConfig struct {
+	URL      string            `yaml:"url"`
+	Job      string            `yaml:"job"`
+	Instance string            `yaml:"instance"`
+	BuildID  string            `yaml:"buildID"`
+	Grouping map[string]string `yaml:"grouping"` // additional grouping labels
+
+	PushInterval   time.Duration `yaml:"pushInterval"`
+	Timeout        time.Duration `yaml:"timeout"`
+	MaxRetries     int           `yaml:"maxRetries"`
+	InitialBackoff time.Duration `yaml:"initialBackoff"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff"`
 }
 
 type DogStatsDConfig struct {
-	HostPort      string        `yaml:"hostPort"`
+	HostPort      string        `yaml:"hostPort"` // "host:port", "unix:///path" or "unixgram:///path"
 	FlushInterval time.Duration `yaml:"flushInterval"`
 	FlushBytes    int           `yaml:"flushBytes"`
+
+	ClientSideAggregation bool          `yaml:"clientSideAggregation"`
+	ExtendedAggregation   bool          `yaml:"extendedAggregation"` // also aggregate histograms, distributions and timings
+	AggregationInterval   time.Duration `yaml:"aggregationInterval"`
+
+	ChannelMode           bool `yaml:"channelMode"`
+	ChannelModeBufferSize int  `yaml:"channelModeBufferSize"`
+
+	Telemetry       bool   `yaml:"telemetry"`
+	OriginDetection bool   `yaml:"originDetection"`
+	ContainerID     string `yaml:"containerID"` // overrides the container ID found by origin detection
 }
 
-func Default() Config {
-	// Read metrics provider from environment, default to prometheus
-	provider := os.Getenv("METRICS_PROVIDER")
-	if provider == "" {
-		provider = "prometheus"
+// CardinalityConfig limits the number of distinct tag combinations each
+// metric may emit and rewrites tags before they reach the reporter.
+type CardinalityConfig struct {
+	Enabled            bool              `yaml:"enabled"`
+	MaxSeriesPerMetric int               `yaml:"maxSeriesPerMetric"` // 0 disables the limit
+	MetricLimits       map[string]int    `yaml:"metricLimits"`       // per-metric overrides of MaxSeriesPerMetric
+	Overflow           string            `yaml:"overflow"`           // "drop" or "bucket"
+	AllowTags          []string          `yaml:"allowTags"`          // when set, only these tag keys are kept
+	DenyTags           []string          `yaml:"denyTags"`
+	RenameTags         map[string]string `yaml:"renameTags"`
+}
+
+// Load returns the defaults, overridden by the YAML file at path (skipped
+// when path is empty) and then by the environment, so an environment variable
+// wins over the file. Unknown keys in the file are errors.
+func Load(path string) (Config, error) {
+	cfg := defaults()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return Config{}, err
 	}
+	return cfg, nil
+}
+
+// Default returns the defaults overridden by the environment. Malformed
+// environment values are ignored; Load reports them.
+func Default() Config {
+	cfg := defaults()
+	_ = cfg.applyEnv()
+	return cfg
+}
+
+func defaults() Config {
+	hostname, _ := os.Hostname()
 
 	return Config{
 		Temporal: TemporalConfig{
@@ -54,17 +237,207 @@ func Default() Config {
 			Port:         4000,
 			ReadTimeout:  30 * time.Second,
 			WriteTimeout: 30 * time.Second,
+
+			WorkflowIDReusePolicy:    "reject-duplicate",
+			WorkflowIDConflictPolicy: "use-existing",
+		},
+		Worker: WorkerConfig{
+			DeploymentName:            "ip-address-go",
+			DefaultVersioningBehavior: "pinned",
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			OTLP: OTLPConfig{
+				Endpoint: "127.0.0.1:4317",
+				Protocol: "grpc",
+				Insecure: true,
+			},
+		},
+		Codec: CodecConfig{
+			Compression: CompressionConfig{
+				Algorithm: "none",
+				Threshold: 4096,
+			},
+			Server: CodecServerConfig{
+				Origins: []string{"http://localhost:8233"},
+			},
 		},
 		Metrics: MetricsConfig{
-			Provider: provider,
+			Provider: "prometheus",
 			Prometheus: PrometheusConfig{
-				ListenAddress: ":9090",
+				ListenAddress:    ":9090",
+				HandlerPath:      "/metrics",
+				GoCollector:      true,
+				ProcessCollector: true,
+				TimerType:        "histogram",
+			},
+			Pushgateway: PushgatewayConfig{
+				URL:            "http://127.0.0.1:9091",
+				Job:            "temporal_samples",
+				Instance:       hostname,
+				PushInterval:   15 * time.Second,
+				Timeout:        10 * time.Second,
+				MaxRetries:     3,
+				InitialBackoff: 500 * time.Millisecond,
+				MaxBackoff:     10 * time.Second,
 			},
 			DogStatsD: DogStatsDConfig{
 				HostPort:      "127.0.0.1:8125",
 				FlushInterval: time.Second,
 				FlushBytes:    1432,
+
+				ClientSideAggregation: true,
+				AggregationInterval:   2 * time.Second,
+				Telemetry:             true,
+				OriginDetection:       true,
+			},
+			Cardinality: CardinalityConfig{
+				Enabled:            true,
+				MaxSeriesPerMetric: 1000,
+				Overflow:           "bucket",
 			},
 		},
 	}
 }
+
+// applyEnv overrides c with the environment variables that are set. It
+// applies every well-formed value and returns the malformed ones as errors.
+func (c *Config) applyEnv() error {
+	var e envParser
+
+	e.string(&c.Metrics.Provider, "METRICS_PROVIDER")
+	e.string(&c.Metrics.Pushgateway.URL, "PUSHGATEWAY_URL")
+	e.string(&c.Metrics.Pushgateway.BuildID, "BUILD_ID")
+	e.bool(&c.Metrics.Cardinality.Enabled, "METRICS_CARDINALITY_ENABLED")
+	e.int(&c.Metrics.Cardinality.MaxSeriesPerMetric, "METRICS_MAX_SERIES_PER_METRIC")
+	e.intMap(&c.Metrics.Cardinality.MetricLimits, "METRICS_METRIC_LIMITS")
+	e.string(&c.Metrics.Cardinality.Overflow, "METRICS_CARDINALITY_OVERFLOW")
+	e.list(&c.Metrics.Cardinality.AllowTags, "METRICS_ALLOW_TAGS")
+	e.list(&c.Metrics.Cardinality.DenyTags, "METRICS_DENY_TAGS")
+	e.stringMap(&c.Metrics.Cardinality.RenameTags, "METRICS_RENAME_TAGS")
+
+	e.string(&c.Logging.Level, "LOG_LEVEL")
+	e.string(&c.Logging.Format, "LOG_FORMAT")
+
+	e.string(&c.Worker.DeploymentName, "WORKER_DEPLOYMENT_NAME")
+	e.string(&c.Worker.BuildID, "BUILD_ID")
+	e.bool(&c.Worker.UseVersioning, "WORKER_VERSIONING")
+	e.string(&c.Worker.BatchDir, "BATCH_DIR")
+	e.string(&c.Worker.WebhookSecret, "WEBHOOK_SECRET")
+	e.string(&c.Worker.RecordDir, "RECORD_DIR")
+
+	e.string(&c.Server.WorkflowIDReusePolicy, "WORKFLOW_ID_REUSE_POLICY")
+	e.string(&c.Server.WorkflowIDConflictPolicy, "WORKFLOW_ID_CONFLICT_POLICY")
+
+	e.string(&c.Codec.Encryption.KeyFile, "ENCRYPTION_KEY_FILE")
+	e.string(&c.Codec.Encryption.Keys, "ENCRYPTION_KEYS")
+	e.string(&c.Codec.Encryption.ActiveKeyID, "ENCRYPTION_KEY_ID")
+	e.string(&c.Codec.Compression.Algorithm, "COMPRESSION_ALGORITHM")
+	e.int(&c.Codec.Compression.Threshold, "COMPRESSION_THRESHOLD")
+	e.bool(&c.Codec.Server.Enabled, "CODEC_SERVER")
+	e.list(&c.Codec.Server.Origins, "CODEC_SERVER_ORIGINS")
+	e.string(&c.Codec.Server.ListenAddress, "CODEC_SERVER_LISTEN_ADDRESS")
+	e.string(&c.Codec.Server.AuthToken, "CODEC_SERVER_AUTH_TOKEN")
+	e.bool(&c.Codec.Server.Insecure, "CODEC_SERVER_INSECURE")
+
+	e.string(&c.Tracing.Exporter, "TRACING_EXPORTER")
+
+	return errors.Join(e.errs...)
+}
+
+// envParser reads environment variables into config fields, collecting the
+// errors of malformed values. Unset and empty variables leave fields alone.
+type envParser struct {
+	errs []error
+}
+
+func (e *envParser) string(dst *string, key string) {
+	if v := os.Getenv(key); v != "" {
+		*dst = v
+	}
+}
+
+func (e *envParser) bool(dst *bool, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not a boolean", key, v))
+		return
+	}
+	*dst = b
+}
+
+func (e *envParser) int(dst *int, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", key, v))
+		return
+	}
+	*dst = n
+}
+
+// list reads comma-separated values
+func (e *envParser) list(dst *[]string, key string) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	var values []string
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	*dst = values
+}
+
+// stringMap reads comma-separated key=value pairs
+func (e *envParser) stringMap(dst *map[string]string, key string) {
+	var values []string
+	e.list(&values, key)
+	if values == nil {
+		return
+	}
+	m := make(map[string]string, len(values))
+	for _, item := range values {
+		k, v, ok := strings.Cut(item, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not key=value", key, item))
+			return
+		}
+		m[k] = v
+	}
+	*dst = m
+}
+
+// intMap reads comma-separated key=integer pairs
+func (e *envParser) intMap(dst *map[string]int, key string) {
+	var pairs map[string]string
+	e.stringMap(&pairs, key)
+	if pairs == nil {
+		return
+	}
+	m := make(map[string]int, len(pairs))
+	for k, v := range pairs {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			e.errs = append(e.errs, fmt.Errorf("%s: %q is not an integer", key, k+"="+v))
+			return
+		}
+		m[k] = n
+	}
+	*dst = m
+}
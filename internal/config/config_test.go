@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadExample(t *testing.T) {
+	// The example documents every setting, so it must load as it is
+	cfg, err := Load("../../config.example.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Metrics.Prometheus.Metrics["temporal_samples_activity_latency_seconds"].HistogramBuckets; len(got) == 0 {
+		t.Error("per-metric histogram buckets were not loaded")
+	}
+	if cfg.Server.ReadTimeout != 30*time.Second {
+		t.Errorf("server read timeout = %v, want 30s", cfg.Server.ReadTimeout)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+metrics:
+  provider: dogstatsd
+  cardinality:
+    maxSeriesPerMetric: 50
+    metricLimits: {activity_latency: 200}
+    allowTags: [workflow_type, stage]
+    renameTags: {workflow_type: wf_type}
+  prometheus:
+    metrics:
+      activity_latency_seconds:
+        histogramBuckets: [0.1, 1, 10]
+logging:
+  level: debug
+`)
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(t *testing.T, cfg Config)
+	}{
+		{
+			name: "file",
+			check: func(t *testing.T, cfg Config) {
+				c := cfg.Metrics.Cardinality
+				if cfg.Metrics.Provider != "dogstatsd" || cfg.Logging.Level != "debug" {
+					t.Errorf("provider = %q, level = %q", cfg.Metrics.Provider, cfg.Logging.Level)
+				}
+				if c.MaxSeriesPerMetric != 50 || c.MetricLimits["activity_latency"] != 200 {
+					t.Errorf("limits = %d, %v", c.MaxSeriesPerMetric, c.MetricLimits)
+				}
+				if !reflect.DeepEqual(c.AllowTags, []string{"workflow_type", "stage"}) || c.RenameTags["workflow_type"] != "wf_type" {
+					t.Errorf("allow = %v, rename = %v", c.AllowTags, c.RenameTags)
+				}
+				// Unset keys keep their defaults
+				if !c.Enabled || c.Overflow != "bucket" || cfg.Temporal.HostPort != "127.0.0.1:7233" {
+					t.Errorf("defaults lost: %+v, %q", c, cfg.Temporal.HostPort)
+				}
+				if got := cfg.Metrics.Prometheus.Metrics["activity_latency_seconds"].HistogramBuckets; !reflect.DeepEqual(got, []float64{0.1, 1, 10}) {
+					t.Errorf("per-metric buckets = %v", got)
+				}
+			},
+		},
+		{
+			name: "environment wins",
+			env: map[string]string{
+				"METRICS_PROVIDER":              "prometheus",
+				"METRICS_MAX_SERIES_PER_METRIC": "10",
+				"METRICS_METRIC_LIMITS":         "a=1, b=2",
+				"METRICS_ALLOW_TAGS":            "",
+				"METRICS_DENY_TAGS":             "workflow_id, run_id",
+				"METRICS_RENAME_TAGS":           "stage=step",
+				"METRICS_CARDINALITY_OVERFLOW":  "drop",
+				"METRICS_CARDINALITY_ENABLED":   "0",
+				"CODEC_SERVER":                  "TRUE",
+			},
+			check: func(t *testing.T, cfg Config) {
+				c := cfg.Metrics.Cardinality
+				if c.Enabled || !cfg.Codec.Server.Enabled {
+					t.Errorf("cardinality enabled = %v, codec server enabled = %v", c.Enabled, cfg.Codec.Server.Enabled)
+				}
+				if cfg.Metrics.Provider != "prometheus" || c.MaxSeriesPerMetric != 10 || c.Overflow != "drop" {
+					t.Errorf("provider = %q, cardinality = %+v", cfg.Metrics.Provider, c)
+				}
+				if !reflect.DeepEqual(c.MetricLimits, map[string]int{"a": 1, "b": 2}) {
+					t.Errorf("metric limits = %v", c.MetricLimits)
+				}
+				if !reflect.DeepEqual(c.DenyTags, []string{"workflow_id", "run_id"}) || !reflect.DeepEqual(c.RenameTags, map[string]string{"stage": "step"}) {
+					t.Errorf("deny = %v, rename = %v", c.DenyTags, c.RenameTags)
+				}
+				// An empty variable leaves the file's value
+				if len(c.AllowTags) != 2 {
+					t.Errorf("allow = %v", c.AllowTags)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "unknown key", file: "metrics:\n  cardinalty: {}\n", wantErr: "cardinalty"},
+		{name: "bad duration", file: "server:\n  readTimeout: soon\n", wantErr: "time.Duration"},
+		{name: "bad boolean", env: map[string]string{"CODEC_SERVER": "yes"}, wantErr: "CODEC_SERVER"},
+		{name: "bad integer", env: map[string]string{"METRICS_MAX_SERIES_PER_METRIC": "many"}, wantErr: "METRICS_MAX_SERIES_PER_METRIC"},
+		{name: "bad pair", env: map[string]string{"METRICS_RENAME_TAGS": "stage"}, wantErr: "not key=value"},
+		{name: "bad limit", env: map[string]string{"METRICS_METRIC_LIMITS": "a=lots"}, wantErr: "not an integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeConfig(t, tt.file)
+			}
+			_, err := Load(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}
@@ -0,0 +1,65 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	sdklog "go.temporal.io/sdk/log"
+)
+
+// Attribute keys shared with the Temporal SDK, which adds the same keys to the
+// workflow and activity loggers it hands out.
+const (
+	WorkflowIDKey = "WorkflowID"
+	RunIDKey      = "RunID"
+	ActivityIDKey = "ActivityID"
+)
+
+// New creates a logger writing to w in the configured format and level
+func New(cfg config.LoggingConfig, w io.Writer) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	return slog.New(handler), nil
+}
+
+// ParseLevel converts "debug", "info", "warn" or "error" to a slog.Level. An
+// empty string means info.
+func ParseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("unknown log level %q", level)
+	}
+	return l, nil
+}
+
+// NewSDKLogger adapts logger to the Temporal SDK logger interface
+func NewSDKLogger(logger *slog.Logger) sdklog.Logger {
+	return sdklog.NewStructuredLogger(logger)
+}
+
+// WithWorkflow returns a logger annotated with a workflow execution
+func WithWorkflow(logger *slog.Logger, workflowID, runID string) *slog.Logger {
+	if runID == "" {
+		return logger.With(WorkflowIDKey, workflowID)
+	}
+	return logger.With(WorkflowIDKey, workflowID, RunIDKey, runID)
+}
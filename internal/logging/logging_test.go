@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+)
+
+func TestNewJSONWithSDKAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(config.LoggingConfig{Level: "info", Format: "json"}, &buf)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	sdkLogger := NewSDKLogger(logger)
+	sdkLogger.Debug("filtered out")
+	sdkLogger.Info("Got IP address", WorkflowIDKey, "wf-1", ActivityIDKey, "5", "ip", "203.0.113.7")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d log lines, want 1:\n%s", len(lines), buf.String())
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("log line is not JSON: %v", err)
+	}
+	want := map[string]string{
+		"level":       "INFO",
+		"msg":         "Got IP address",
+		WorkflowIDKey: "wf-1",
+		ActivityIDKey: "5",
+		"ip":          "203.0.113.7",
+	}
+	for k, v := range want {
+		if record[k] != v {
+			t.Errorf("%s = %v, want %q", k, record[k], v)
+		}
+	}
+}
+
+func TestNewRejectsUnknownSettings(t *testing.T) {
+	if _, err := New(config.LoggingConfig{Level: "loud"}, &bytes.Buffer{}); err == nil {
+		t.Error("unknown level accepted")
+	}
+	if _, err := New(config.LoggingConfig{Format: "xml"}, &bytes.Buffer{}); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,356 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	"github.com/uber-go/tally/v4"
+)
+
+const (
+	// overflowTagValue replaces every tag value of a series that exceeds its
+	// metric's limit when the overflow mode is "bucket".
+	overflowTagValue = "other"
+
+	// droppedSeriesMetric counts the distinct series that were dropped or
+	// bucketed, tagged with the metric they belonged to.
+	droppedSeriesMetric = "metrics_cardinality_dropped_series"
+
+	overflowDrop = "drop"
+)
+
+// cardinalityGuard rewrites tags according to the allow/deny lists and renames
+// and tracks the distinct tag combinations seen for each metric.
+type cardinalityGuard struct {
+	allow  map[string]struct{}
+	deny   map[string]struct{}
+	rename map[string]string
+	limits map[string]int
+	max    int
+	bucket bool
+
+	mu     sync.Mutex
+	series map[string]map[string]struct{}
+	// overflowed holds the series over their metric's limit, so each is
+	// counted as dropped once however often it is reported
+	overflowed map[string]map[string]struct{}
+	dropped    map[string]int64
+}
+
+func newCardinalityGuard(cfg config.CardinalityConfig) (*cardinalityGuard, error) {
+	// Two tags renamed to one key would leave map order to pick the value
+	sources := make(map[string]string, len(cfg.RenameTags))
+	for from, to := range cfg.RenameTags {
+		if other, ok := sources[to]; ok {
+			first, second := min(from, other), max(from, other)
+			return nil, fmt.Errorf("tags %q and %q are both renamed to %q", first, second, to)
+		}
+		sources[to] = from
+	}
+
+	g := &cardinalityGuard{
+		rename:     cfg.RenameTags,
+		limits:     cfg.MetricLimits,
+		max:        cfg.MaxSeriesPerMetric,
+		bucket:     cfg.Overflow != overflowDrop,
+		series:     make(map[string]map[string]struct{}),
+		overflowed: make(map[string]map[string]struct{}),
+		dropped:    make(map[string]int64),
+	}
+	if len(cfg.AllowTags) > 0 {
+		g.allow = make(map[string]struct{}, len(cfg.AllowTags))
+		for _, k := range cfg.AllowTags {
+			g.allow[k] = struct{}{}
+		}
+	}
+	if len(cfg.DenyTags) > 0 {
+		g.deny = make(map[string]struct{}, len(cfg.DenyTags))
+		for _, k := range cfg.DenyTags {
+			g.deny[k] = struct{}{}
+		}
+	}
+	return g, nil
+}
+
+// filter applies the allow list, deny list and renames to tags. A renamed tag
+// replaces a tag already named like its new name. The input map is never
+// modified since tally shares it between reports.
+func (g *cardinalityGuard) filter(tags map[string]string) map[string]string {
+	if g.allow == nil && g.deny == nil && len(g.rename) == 0 {
+		return tags
+	}
+	out := make(map[string]string, len(tags))
+	var renamed map[string]string
+	for k, v := range tags {
+		if g.allow != nil {
+			if _, ok := g.allow[k]; !ok {
+				continue
+			}
+		}
+		if _, ok := g.deny[k]; ok {
+			continue
+		}
+		if to, ok := g.rename[k]; ok {
+			if renamed == nil {
+				renamed = make(map[string]string, len(g.rename))
+			}
+			renamed[to] = v
+			continue
+		}
+		out[k] = v
+	}
+	for k, v := range renamed {
+		out[k] = v
+	}
+	return out
+}
+
+func (g *cardinalityGuard) limit(name string) int {
+	if limit, ok := g.limits[name]; ok {
+		return limit
+	}
+	return g.max
+}
+
+// apply returns the tags to report for a series and whether it should be
+// reported at all.
+func (g *cardinalityGuard) apply(name string, tags map[string]string) (map[string]string, bool) {
+	tags = g.filter(tags)
+
+	limit := g.limit(name)
+	if limit <= 0 {
+		return tags, true
+	}
+
+	key := seriesKey(tags)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	seen, ok := g.series[name]
+	if !ok {
+		seen = make(map[string]struct{})
+		g.series[name] = seen
+	}
+	if _, ok := seen[key]; ok {
+		return tags, true
+	}
+	if len(seen) < limit {
+		seen[key] = struct{}{}
+		return tags, true
+	}
+
+	overflowed, ok := g.overflowed[name]
+	if !ok {
+		overflowed = make(map[string]struct{})
+		g.overflowed[name] = overflowed
+	}
+	if _, ok := overflowed[key]; !ok {
+		overflowed[key] = struct{}{}
+		g.dropped[name]++
+	}
+	if !g.bucket {
+		return nil, false
+	}
+	return overflowTags(tags), true
+}
+
+// drainDropped returns the number of series newly dropped since the last
+// call.
+func (g *cardinalityGuard) drainDropped() map[string]int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.dropped) == 0 {
+		return nil
+	}
+	dropped := g.dropped
+	g.dropped = make(map[string]int64)
+	return dropped
+}
+
+func seriesKey(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// overflowTags keeps the tag keys so that reporters requiring a consistent
+// label set per metric (Prometheus) accept the bucketed series.
+func overflowTags(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags))
+	for k := range tags {
+		out[k] = overflowTagValue
+	}
+	return out
+}
+
+type cardinalityReporter struct {
+	reporter tally.StatsReporter
+	guard    *cardinalityGuard
+}
+
+// NewCardinalityReporter wraps reporter so that every metric is limited to the
+// configured number of distinct tag combinations. It fails when cfg renames
+// two tags to the same name.
+func NewCardinalityReporter(reporter tally.StatsReporter, cfg config.CardinalityConfig) (tally.StatsReporter, error) {
+	guard, err := newCardinalityGuard(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &cardinalityReporter{
+		reporter: reporter,
+		guard:    guard,
+	}, nil
+}
+
+func (r *cardinalityReporter) Capabilities() tally.Capabilities {
+	return r.reporter.Capabilities()
+}
+
+func (r *cardinalityReporter) Flush() {
+	for name, count := range r.guard.drainDropped() {
+		r.reporter.ReportCounter(droppedSeriesMetric, map[string]string{"metric": name}, count)
+	}
+	r.reporter.Flush()
+}
+
+func (r *cardinalityReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	if tags, ok := r.guard.apply(name, tags); ok {
+		r.reporter.ReportCounter(name, tags, value)
+	}
+}
+
+func (r *cardinalityReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	if tags, ok := r.guard.apply(name, tags); ok {
+		r.reporter.ReportGauge(name, tags, value)
+	}
+}
+
+func (r *cardinalityReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	if tags, ok := r.guard.apply(name, tags); ok {
+		r.reporter.ReportTimer(name, tags, interval)
+	}
+}
+
+func (r *cardinalityReporter) ReportHistogramValueSamples(name string, tags map[string]string, buckets tally.Buckets, bucketLowerBound, bucketUpperBound float64, samples int64) {
+	if tags, ok := r.guard.apply(name, tags); ok {
+		r.reporter.ReportHistogramValueSamples(name, tags, buckets, bucketLowerBound, bucketUpperBound, samples)
+	}
+}
+
+func (r *cardinalityReporter) ReportHistogramDurationSamples(name string, tags map[string]string, buckets tally.Buckets, bucketLowerBound, bucketUpperBound time.Duration, samples int64) {
+	if tags, ok := r.guard.apply(name, tags); ok {
+		r.reporter.ReportHistogramDurationSamples(name, tags, buckets, bucketLowerBound, bucketUpperBound, samples)
+	}
+}
+
+type cardinalityCachedReporter struct {
+	reporter tally.CachedStatsReporter
+	guard    *cardinalityGuard
+
+	mu      sync.Mutex
+	dropped map[string]tally.CachedCount
+}
+
+// NewCardinalityCachedReporter is the tally.CachedStatsReporter counterpart of
+// NewCardinalityReporter. Limits are enforced when a series is allocated.
+func NewCardinalityCachedReporter(reporter tally.CachedStatsReporter, cfg config.CardinalityConfig) (tally.CachedStatsReporter, error) {
+	guard, err := newCardinalityGuard(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &cardinalityCachedReporter{
+		reporter: reporter,
+		guard:    guard,
+		dropped:  make(map[string]tally.CachedCount),
+	}, nil
+}
+
+func (r *cardinalityCachedReporter) Capabilities() tally.Capabilities {
+	return r.reporter.Capabilities()
+}
+
+func (r *cardinalityCachedReporter) Flush() {
+	for name, count := range r.guard.drainDropped() {
+		r.droppedCounter(name).ReportCount(count)
+	}
+	r.reporter.Flush()
+}
+
+func (r *cardinalityCachedReporter) droppedCounter(name string) tally.CachedCount {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counter, ok := r.dropped[name]
+	if !ok {
+		counter = r.reporter.AllocateCounter(droppedSeriesMetric, map[string]string{"metric": name})
+		r.dropped[name] = counter
+	}
+	return counter
+}
+
+func (r *cardinalityCachedReporter) AllocateCounter(name string, tags map[string]string) tally.CachedCount {
+	tags, ok := r.guard.apply(name, tags)
+	if !ok {
+		return noopCachedMetric{}
+	}
+	return r.reporter.AllocateCounter(name, tags)
+}
+
+func (r *cardinalityCachedReporter) AllocateGauge(name string, tags map[string]string) tally.CachedGauge {
+	tags, ok := r.guard.apply(name, tags)
+	if !ok {
+		return noopCachedMetric{}
+	}
+	return r.reporter.AllocateGauge(name, tags)
+}
+
+func (r *cardinalityCachedReporter) AllocateTimer(name string, tags map[string]string) tally.CachedTimer {
+	tags, ok := r.guard.apply(name, tags)
+	if !ok {
+		return noopCachedMetric{}
+	}
+	return r.reporter.AllocateTimer(name, tags)
+}
+
+func (r *cardinalityCachedReporter) AllocateHistogram(name string, tags map[string]string, buckets tally.Buckets) tally.CachedHistogram {
+	tags, ok := r.guard.apply(name, tags)
+	if !ok {
+		return noopCachedMetric{}
+	}
+	return r.reporter.AllocateHistogram(name, tags, buckets)
+}
+
+// noopCachedMetric is handed out for series dropped by the guard.
+type noopCachedMetric struct{}
+
+func (noopCachedMetric) ReportCount(int64)         {}
+func (noopCachedMetric) ReportGauge(float64)       {}
+func (noopCachedMetric) ReportTimer(time.Duration) {}
+func (noopCachedMetric) ReportSamples(int64)       {}
+func (noopCachedMetric) ValueBucket(_, _ float64) tally.CachedHistogramBucket {
+	return noopCachedMetric{}
+}
+func (noopCachedMetric) DurationBucket(_, _ time.Duration) tally.CachedHistogramBucket {
+	return noopCachedMetric{}
+}
@@ -0,0 +1,268 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	"github.com/uber-go/tally/v4"
+)
+
+// newCardinalityReporter wraps memory in a cardinality reporter for cfg
+func newCardinalityReporter(t *testing.T, memory *MemoryReporter, cfg config.CardinalityConfig) tally.StatsReporter {
+	t.Helper()
+
+	reporter, err := NewCardinalityReporter(memory, cfg)
+	if err != nil {
+		t.Fatalf("NewCardinalityReporter: %v", err)
+	}
+	return reporter
+}
+
+// reportSeries reports one counter increment per tag set to reporter, twice, as a
+// scope reports the same series on every flush
+func reportSeries(reporter tally.StatsReporter, name string, tagSets []map[string]string) {
+	for range 2 {
+		for _, tags := range tagSets {
+			reporter.ReportCounter(name, tags, 1)
+		}
+		reporter.Flush()
+	}
+}
+
+func providerTags(n int) []map[string]string {
+	tagSets := make([]map[string]string, n)
+	for i := range tagSets {
+		tagSets[i] = map[string]string{"provider": fmt.Sprintf("p%d", i)}
+	}
+	return tagSets
+}
+
+func TestCardinalityReporterTags(t *testing.T) {
+	tags := []map[string]string{{"workflow_type": "GetAddressFromIP", "workflow_id": "wf-1", "stage": "GetIP"}}
+
+	tests := []struct {
+		name string
+		cfg  config.CardinalityConfig
+		// want maps "key=value" tag queries to the count they should match
+		want map[string]int64
+	}{
+		{
+			name: "unchanged",
+			cfg:  config.CardinalityConfig{},
+			want: map[string]int64{"workflow_id=wf-1": 2, "stage=GetIP": 2},
+		},
+		{
+			name: "allow list",
+			cfg:  config.CardinalityConfig{AllowTags: []string{"workflow_type"}},
+			want: map[string]int64{"workflow_type=GetAddressFromIP": 2, "workflow_id=wf-1": 0, "stage=GetIP": 0},
+		},
+		{
+			name: "deny list",
+			cfg:  config.CardinalityConfig{DenyTags: []string{"workflow_id"}},
+			want: map[string]int64{"workflow_type=GetAddressFromIP": 2, "workflow_id=wf-1": 0, "stage=GetIP": 2},
+		},
+		{
+			name: "rename",
+			cfg:  config.CardinalityConfig{RenameTags: map[string]string{"workflow_type": "wf_type"}},
+			want: map[string]int64{"wf_type=GetAddressFromIP": 2, "workflow_type=GetAddressFromIP": 0},
+		},
+		{
+			name: "deny applies before rename",
+			cfg: config.CardinalityConfig{
+				DenyTags:   []string{"workflow_id"},
+				RenameTags: map[string]string{"workflow_id": "id", "stage": "step"},
+			},
+			want: map[string]int64{"id=wf-1": 0, "step=GetIP": 2},
+		},
+		{
+			// The renamed tag replaces the one it is renamed to
+			name: "rename onto an existing tag",
+			cfg:  config.CardinalityConfig{RenameTags: map[string]string{"workflow_id": "stage"}},
+			want: map[string]int64{"stage=wf-1": 2, "stage=GetIP": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewMemoryReporter()
+			reportSeries(newCardinalityReporter(t, memory, tt.cfg), "lookups", tags)
+
+			for query, want := range tt.want {
+				key, value, _ := strings.Cut(query, "=")
+				memory.AssertCounter(t, "lookups", map[string]string{key: value}, want)
+			}
+		})
+	}
+}
+
+func TestCardinalityReporterLimits(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.CardinalityConfig
+		metric    string
+		series    int
+		wantKept  int // distinct provider tag values reported as they are
+		wantOther int64
+		// wantDropped is the dropped series counter, counting each series
+		// over the limit once however often it is reported
+		wantDropped int64
+	}{
+		{
+			name:   "under the limit",
+			cfg:    config.CardinalityConfig{MaxSeriesPerMetric: 5, Overflow: "bucket"},
+			metric: "lookups", series: 3, wantKept: 3,
+		},
+		{
+			name:   "bucket",
+			cfg:    config.CardinalityConfig{MaxSeriesPerMetric: 2, Overflow: "bucket"},
+			metric: "lookups", series: 5, wantKept: 2, wantOther: 6, wantDropped: 3,
+		},
+		{
+			name:   "drop",
+			cfg:    config.CardinalityConfig{MaxSeriesPerMetric: 2, Overflow: "drop"},
+			metric: "lookups", series: 5, wantKept: 2, wantDropped: 3,
+		},
+		{
+			name: "per-metric limit",
+			cfg: config.CardinalityConfig{
+				MaxSeriesPerMetric: 2,
+				MetricLimits:       map[string]int{"lookups": 4},
+				Overflow:           "drop",
+			},
+			metric: "lookups", series: 5, wantKept: 4, wantDropped: 1,
+		},
+		{
+			name: "per-metric limit of another metric",
+			cfg: config.CardinalityConfig{
+				MaxSeriesPerMetric: 2,
+				MetricLimits:       map[string]int{"lookups": 4},
+				Overflow:           "drop",
+			},
+			metric: "requests", series: 5, wantKept: 2, wantDropped: 3,
+		},
+		{
+			name:   "no limit",
+			cfg:    config.CardinalityConfig{MaxSeriesPerMetric: 0, Overflow: "drop"},
+			metric: "lookups", series: 50, wantKept: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := NewMemoryReporter()
+			reportSeries(newCardinalityReporter(t, memory, tt.cfg), tt.metric, providerTags(tt.series))
+
+			kept := 0
+			for _, tags := range providerTags(tt.series) {
+				if memory.Counter(tt.metric, tags) > 0 {
+					kept++
+				}
+			}
+			if kept != tt.wantKept {
+				t.Errorf("kept %d series, want %d", kept, tt.wantKept)
+			}
+			memory.AssertCounter(t, tt.metric, map[string]string{"provider": overflowTagValue}, tt.wantOther)
+			memory.AssertCounter(t, droppedSeriesMetric, map[string]string{"metric": tt.metric}, tt.wantDropped)
+		})
+	}
+}
+
+// cachedMemoryReporter adapts MemoryReporter to tally.CachedStatsReporter,
+// counting the series allocated
+type cachedMemoryReporter struct {
+	*MemoryReporter
+	allocated int
+}
+
+type cachedMemoryCounter struct {
+	reporter *MemoryReporter
+	name     string
+	tags     map[string]string
+}
+
+func (c cachedMemoryCounter) ReportCount(value int64) {
+	c.reporter.ReportCounter(c.name, c.tags, value)
+}
+
+func (r *cachedMemoryReporter) AllocateCounter(name string, tags map[string]string) tally.CachedCount {
+	r.allocated++
+	return cachedMemoryCounter{reporter: r.MemoryReporter, name: name, tags: tags}
+}
+
+func (r *cachedMemoryReporter) AllocateGauge(string, map[string]string) tally.CachedGauge {
+	return noopCachedMetric{}
+}
+
+func (r *cachedMemoryReporter) AllocateTimer(string, map[string]string) tally.CachedTimer {
+	return noopCachedMetric{}
+}
+
+func (r *cachedMemoryReporter) AllocateHistogram(string, map[string]string, tally.Buckets) tally.CachedHistogram {
+	return noopCachedMetric{}
+}
+
+func TestCardinalityCachedReporter(t *testing.T) {
+	tests := []struct {
+		name          string
+		overflow      string
+		wantOther     int64
+		wantAllocated int // series allocated in the wrapped reporter, besides the dropped counter
+	}{
+		{name: "bucket", overflow: "bucket", wantOther: 6, wantAllocated: 5},
+		{name: "drop", overflow: "drop", wantOther: 0, wantAllocated: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			memory := &cachedMemoryReporter{MemoryReporter: NewMemoryReporter()}
+			cfg := config.CardinalityConfig{MaxSeriesPerMetric: 2, Overflow: tt.overflow, DenyTags: []string{"workflow_id"}}
+			reporter, err := NewCardinalityCachedReporter(memory, cfg)
+			if err != nil {
+				t.Fatalf("NewCardinalityCachedReporter: %v", err)
+			}
+			scope, closer := tally.NewRootScope(tally.ScopeOptions{
+				CachedReporter:         reporter,
+				OmitCardinalityMetrics: true,
+			}, time.Hour)
+
+			for range 2 {
+				for _, tags := range providerTags(5) {
+					tags["workflow_id"] = "wf-1"
+					scope.Tagged(tags).Counter("lookups").Inc(1)
+				}
+			}
+			if err := closer.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			memory.AssertCounter(t, "lookups", map[string]string{"provider": "p0"}, 2)
+			memory.AssertCounter(t, "lookups", map[string]string{"workflow_id": "wf-1"}, 0)
+			memory.AssertCounter(t, "lookups", map[string]string{"provider": overflowTagValue}, tt.wantOther)
+			memory.AssertCounter(t, droppedSeriesMetric, map[string]string{"metric": "lookups"}, 3)
+			if got := memory.allocated - 1; got != tt.wantAllocated {
+				t.Errorf("allocated %d series, want %d", got, tt.wantAllocated)
+			}
+		})
+	}
+}
+
+func TestCardinalityReporterRenameConflict(t *testing.T) {
+	cfg := config.CardinalityConfig{RenameTags: map[string]string{"workflow_type": "type", "activity_type": "type"}}
+
+	_, err := NewCardinalityReporter(NewMemoryReporter(), cfg)
+	if err == nil || !strings.Contains(err.Error(), `"activity_type" and "workflow_type"`) {
+		t.Errorf("NewCardinalityReporter err = %v, want the conflicting tags named", err)
+	}
+	if _, err := NewCardinalityCachedReporter(&cachedMemoryReporter{MemoryReporter: NewMemoryReporter()}, cfg); err == nil {
+		t.Error("NewCardinalityCachedReporter accepted conflicting renames")
+	}
+
+	// Swapping two names is not a conflict
+	swap := config.CardinalityConfig{RenameTags: map[string]string{"a": "b", "b": "a"}}
+	if _, err := NewCardinalityReporter(NewMemoryReporter(), swap); err != nil {
+		t.Errorf("NewCardinalityReporter with swapped names: %v", err)
+	}
+}
@@ -5,7 +5,7 @@ import (
 	"sort"
 	"time"
 
-	"github.com/DataDog/datadog-go/statsd"
+	"github.com/DataDog/datadog-go/v5/statsd"
 	"github.com/natemollica-nm/temporal/internal/config"
 	"github.com/uber-go/tally/v4"
 	"go.temporal.io/sdk/log"
@@ -21,8 +21,14 @@ type dogstatsdReporter struct {
 	logger log.Logger
 }
 
-// NewDogStatsDReporter creates a new DogStatsD metrics reporter
+// NewDogStatsDReporter creates a new DogStatsD metrics reporter. HostPort may be
+// a UDP "host:port" or a Unix domain socket address using the "unix://" or
+// "unixgram://" prefix.
 func NewDogStatsDReporter(config config.DogStatsDConfig, logger log.Logger) (tally.StatsReporter, error) {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+
 	hostPort := config.HostPort
 	if hostPort == "" {
 		hostPort = "127.0.0.1:8125"
@@ -38,11 +44,44 @@ func NewDogStatsDReporter(config config.DogStatsDConfig, logger log.Logger) (tal
 		flushBytes = defaultFlushBytes
 	}
 
-	client, err := statsd.New(
-		hostPort,
+	opts := []statsd.Option{
 		statsd.WithBufferFlushInterval(flushInterval),
 		statsd.WithMaxBytesPerPayload(flushBytes),
-	)
+	}
+
+	if config.ClientSideAggregation {
+		opts = append(opts, statsd.WithClientSideAggregation())
+		if config.ExtendedAggregation {
+			opts = append(opts, statsd.WithExtendedClientSideAggregation())
+		}
+		if config.AggregationInterval > 0 {
+			opts = append(opts, statsd.WithAggregationInterval(config.AggregationInterval))
+		}
+	} else {
+		opts = append(opts, statsd.WithoutClientSideAggregation())
+	}
+
+	if config.ChannelMode {
+		opts = append(opts, statsd.WithChannelMode())
+		if config.ChannelModeBufferSize > 0 {
+			opts = append(opts, statsd.WithChannelModeBufferSize(config.ChannelModeBufferSize))
+		}
+	}
+
+	if !config.Telemetry {
+		opts = append(opts, statsd.WithoutTelemetry())
+	}
+
+	if config.OriginDetection {
+		opts = append(opts, statsd.WithOriginDetection())
+		if config.ContainerID != "" {
+			opts = append(opts, statsd.WithContainerID(config.ContainerID))
+		}
+	} else {
+		opts = append(opts, statsd.WithoutOriginDetection())
+	}
+
+	client, err := statsd.New(hostPort, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create DogStatsD client: %w", err)
 	}
@@ -71,6 +110,11 @@ func (r *dogstatsdReporter) Flush() {
 	}
 }
 
+// Close flushes any buffered metrics and closes the DogStatsD client
+func (r *dogstatsdReporter) Close() error {
+	return r.client.Close()
+}
+
 func (r *dogstatsdReporter) ReportCounter(name string, tags map[string]string, value int64) {
 	name = r.sanitizeMetricName(name)
 	if err := r.client.Count(name, value, r.marshalTags(tags), 1); err != nil {
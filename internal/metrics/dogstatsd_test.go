@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	"github.com/uber-go/tally/v4"
+)
+
+// listenUnixgram starts a unixgram listener standing in for the Datadog agent.
+func listenUnixgram(t *testing.T) (string, *net.UnixConn) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "dsd.socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return path, conn
+}
+
+// expectLines reads datagrams until every wanted line has been received or
+// the deadline passes.
+func expectLines(t *testing.T, conn *net.UnixConn, want ...string) {
+	t.Helper()
+
+	missing := make(map[string]bool, len(want))
+	for _, w := range want {
+		missing[w] = true
+	}
+
+	var received []string
+	buf := make([]byte, 65536)
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("set read deadline: %v", err)
+	}
+	for len(missing) > 0 {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("missing %v, received %q: %v", missing, received, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(buf[:n])), "\n") {
+			received = append(received, line)
+			delete(missing, line)
+		}
+	}
+}
+
+func newTestDogStatsDReporter(t *testing.T, cfg config.DogStatsDConfig) tally.StatsReporter {
+	t.Helper()
+
+	reporter, err := NewDogStatsDReporter(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewDogStatsDReporter: %v", err)
+	}
+	return reporter
+}
+
+func TestDogStatsDReporterUnixgram(t *testing.T) {
+	for _, prefix := range []string{"unixgram://", "unix://"} {
+		t.Run(prefix, func(t *testing.T) {
+			path, conn := listenUnixgram(t)
+
+			reporter := newTestDogStatsDReporter(t, config.DogStatsDConfig{
+				HostPort:        prefix + path,
+				FlushInterval:   time.Hour,
+				OriginDetection: false,
+			})
+
+			reporter.ReportCounter("requests", map[string]string{"stage": "GetIP"}, 3)
+			reporter.Flush()
+
+			expectLines(t, conn, "temporal.requests:3|c|#stage:GetIP")
+		})
+	}
+}
+
+func TestDogStatsDReporterClientSideAggregation(t *testing.T) {
+	path, conn := listenUnixgram(t)
+
+	reporter := newTestDogStatsDReporter(t, config.DogStatsDConfig{
+		HostPort:              "unixgram://" + path,
+		FlushInterval:         time.Hour,
+		ClientSideAggregation: true,
+		ExtendedAggregation:   true,
+		AggregationInterval:   time.Hour,
+		ChannelMode:           true,
+		ChannelModeBufferSize: 16,
+	})
+
+	for i := 0; i < 5; i++ {
+		reporter.ReportCounter("requests", map[string]string{"stage": "GetIP"}, 2)
+	}
+	reporter.ReportGauge("inflight", nil, 1)
+	reporter.ReportGauge("inflight", nil, 4)
+	reporter.Flush()
+
+	expectLines(t, conn, "temporal.requests:10|c|#stage:GetIP", "temporal.inflight:4|g")
+}
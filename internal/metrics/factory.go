@@ -1,8 +1,12 @@
 package metrics
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/natemollica-nm/temporal/internal/config"
@@ -15,16 +19,54 @@ import (
 
 // Factory creates metrics scopes based on configuration
 type Factory struct {
-	config config.MetricsConfig
-	logger sdklog.Logger
+	config   config.MetricsConfig
+	logger   sdklog.Logger
+	registry *prom.Registry
+	handler  http.Handler
+
+	// scopeCloser flushes the root scope, pusher then pushes the flushed
+	// values, and closers release the reporters afterwards.
+	scopeCloser io.Closer
+	pusher      *pushgatewayPusher
+	closers     []io.Closer
+}
+
+// defaultLogger is used when no logger is supplied so that reporters never
+// call methods on a nil logger.
+func defaultLogger() sdklog.Logger {
+	return sdklog.NewStructuredLogger(slog.Default())
+}
+
+// FactoryOption customizes a Factory
+type FactoryOption func(*Factory)
+
+// WithPrometheusRegistry makes the Prometheus provider register its metrics
+// on registry instead of a fresh one.
+func WithPrometheusRegistry(registry *prom.Registry) FactoryOption {
+	return func(f *Factory) {
+		f.registry = registry
+	}
 }
 
 // NewFactory creates a new metrics factory
-func NewFactory(cfg config.MetricsConfig, logger sdklog.Logger) *Factory {
-	return &Factory{
+func NewFactory(cfg config.MetricsConfig, logger sdklog.Logger, opts ...FactoryOption) *Factory {
+	if logger == nil {
+		logger = defaultLogger()
+	}
+	f := &Factory{
 		config: cfg,
 		logger: logger,
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Handler returns the HTTP handler exposing the scope created by CreateScope,
+// or nil if the provider is not scraped over HTTP.
+func (f *Factory) Handler() http.Handler {
+	return f.handler
 }
 
 // CreateScope creates a tally scope based on the configured provider
@@ -34,16 +76,44 @@ func (f *Factory) CreateScope() (tally.Scope, error) {
 		return f.createDogStatsDScope()
 	case "prometheus":
 		return f.createPrometheusScope()
+	case "prometheus-push":
+		return f.createPushgatewayScope()
 	default:
 		return f.createPrometheusScope() // Default to Prometheus
 	}
 }
 
+// Close flushes the scope created by CreateScope, pushes the final metrics
+// for the "prometheus-push" provider and releases the reporters.
+func (f *Factory) Close(ctx context.Context) error {
+	var errs []error
+	if f.scopeCloser != nil {
+		errs = append(errs, f.scopeCloser.Close())
+	}
+	if f.pusher != nil {
+		errs = append(errs, f.pusher.Close(ctx))
+	}
+	for _, closer := range f.closers {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
 func (f *Factory) createDogStatsDScope() (tally.Scope, error) {
 	reporter, err := NewDogStatsDReporter(f.config.DogStatsD, f.logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create DogStatsD reporter: %w", err)
 	}
+	if closer, ok := reporter.(io.Closer); ok {
+		f.closers = append(f.closers, closer)
+	}
+
+	if f.config.Cardinality.Enabled {
+		reporter, err = NewCardinalityReporter(reporter, f.config.Cardinality)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cardinality configuration: %w", err)
+		}
+	}
 
 	scopeOpts := tally.ScopeOptions{
 		Reporter:  reporter,
@@ -51,32 +121,85 @@ func (f *Factory) createDogStatsDScope() (tally.Scope, error) {
 		Prefix:    "temporal_samples",
 	}
 
-	scope, _ := tally.NewRootScope(scopeOpts, time.Second)
+	scope, closer := tally.NewRootScope(scopeOpts, time.Second)
+	f.scopeCloser = closer
 	return scope, nil
 }
 
 func (f *Factory) createPrometheusScope() (tally.Scope, error) {
-	registry := prom.NewRegistry()
-	reporter, err := prometheus.Configuration{
-		ListenAddress: f.config.Prometheus.ListenAddress,
-		TimerType:     "histogram",
-	}.NewReporter(prometheus.ConfigurationOptions{
-		Registry: registry,
-		OnError: func(err error) {
-			log.Println("prometheus reporter error:", err)
-		},
+	cfg := f.config.Prometheus
+
+	registry, reporter, err := f.createPrometheusReporter()
+	if err != nil {
+		return nil, err
+	}
+
+	f.handler = newPrometheusHandler(cfg, registry)
+	if cfg.ListenAddress != "" {
+		f.closers = append(f.closers, servePrometheus(cfg, f.handler, f.logger))
+	}
+
+	return f.newPrometheusRootScope(reporter, false), nil
+}
+
+func (f *Factory) createPushgatewayScope() (tally.Scope, error) {
+	registry, reporter, err := f.createPrometheusReporter()
+	if err != nil {
+		return nil, err
+	}
+
+	pusher, err := newPushgatewayPusher(f.config.Pushgateway, registry, f.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pushgateway pusher: %w", err)
+	}
+
+	// tally's internal cardinality metrics carry an "instance" label, which
+	// the Pushgateway rejects when it is also part of the grouping key.
+	scope := f.newPrometheusRootScope(reporter, true)
+
+	pusher.Start()
+	f.pusher = pusher
+	return scope, nil
+}
+
+func (f *Factory) createPrometheusReporter() (*prom.Registry, tally.CachedStatsReporter, error) {
+	cfg := f.config.Prometheus
+
+	registry := f.registry
+	if registry == nil {
+		registry = prom.NewRegistry()
+	}
+	if err := registerRuntimeCollectors(cfg, registry); err != nil {
+		return nil, nil, fmt.Errorf("failed to register prometheus collectors: %w", err)
+	}
+
+	promReporter, err := newPrometheusReporter(cfg, registry, func(err error) {
+		f.logger.Error("Prometheus reporter error", "error", err)
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create prometheus reporter: %w", err)
+		return nil, nil, fmt.Errorf("failed to create prometheus reporter: %w", err)
 	}
 
+	var reporter tally.CachedStatsReporter = promReporter
+	if f.config.Cardinality.Enabled {
+		reporter, err = NewCardinalityCachedReporter(reporter, f.config.Cardinality)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid cardinality configuration: %w", err)
+		}
+	}
+	return registry, reporter, nil
+}
+
+func (f *Factory) newPrometheusRootScope(reporter tally.CachedStatsReporter, omitCardinalityMetrics bool) tally.Scope {
 	scopeOpts := tally.ScopeOptions{
-		CachedReporter:  reporter,
-		Separator:       prometheus.DefaultSeparator,
-		SanitizeOptions: &sdktally.PrometheusSanitizeOptions,
-		Prefix:          "temporal_samples",
+		CachedReporter:         reporter,
+		Separator:              prometheus.DefaultSeparator,
+		SanitizeOptions:        &sdktally.PrometheusSanitizeOptions,
+		Prefix:                 "temporal_samples",
+		OmitCardinalityMetrics: omitCardinalityMetrics,
 	}
 
-	scope, _ := tally.NewRootScope(scopeOpts, time.Second)
-	return sdktally.NewPrometheusNamingScope(scope), nil
+	scope, closer := tally.NewRootScope(scopeOpts, time.Second)
+	f.scopeCloser = closer
+	return sdktally.NewPrometheusNamingScope(scope)
 }
@@ -0,0 +1,312 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/uber-go/tally/v4"
+	"go.temporal.io/sdk/client"
+)
+
+// TB is the subset of testing.TB used by the assertion helpers, so this
+// package does not have to import "testing".
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// memorySeries holds every value recorded for one metric name and tag set.
+type memorySeries struct {
+	name string
+	tags map[string]string
+
+	counter   int64
+	gauge     float64
+	gaugeSeq  uint64 // order of the last gauge update across all series
+	timers    []time.Duration
+	histogram map[float64]int64 // samples keyed by bucket upper bound
+}
+
+// MemoryReporter records metrics in memory so tests can assert on them
+// without a Prometheus or StatsD backend. It is both a tally.StatsReporter and,
+// through MetricsHandler, a Temporal SDK metrics handler that records
+// synchronously.
+//
+// Queries match every series whose tags contain the given tags and sum (or,
+// for gauges, take the last) values across them. A nil tag map matches all
+// series of that name.
+type MemoryReporter struct {
+	mu         sync.Mutex
+	counters   map[string]*memorySeries
+	gauges     map[string]*memorySeries
+	timers     map[string]*memorySeries
+	histograms map[string]*memorySeries
+	gaugeSeq   uint64
+}
+
+// NewMemoryReporter creates an empty MemoryReporter.
+func NewMemoryReporter() *MemoryReporter {
+	r := &MemoryReporter{}
+	r.Reset()
+	return r
+}
+
+// Reset discards everything recorded so far.
+func (r *MemoryReporter) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counters = make(map[string]*memorySeries)
+	r.gauges = make(map[string]*memorySeries)
+	r.timers = make(map[string]*memorySeries)
+	r.histograms = make(map[string]*memorySeries)
+}
+
+// MetricsHandler returns an SDK metrics handler recording into r.
+func (r *MemoryReporter) MetricsHandler() client.MetricsHandler {
+	return memoryHandler{reporter: r}
+}
+
+func (r *MemoryReporter) series(m map[string]*memorySeries, name string, tags map[string]string) *memorySeries {
+	key := name + "|" + seriesKey(tags)
+	s, ok := m[key]
+	if !ok {
+		copied := make(map[string]string, len(tags))
+		for k, v := range tags {
+			copied[k] = v
+		}
+		s = &memorySeries{name: name, tags: copied}
+		m[key] = s
+	}
+	return s
+}
+
+func (r *MemoryReporter) Capabilities() tally.Capabilities {
+	return r
+}
+
+func (r *MemoryReporter) Reporting() bool {
+	return true
+}
+
+func (r *MemoryReporter) Tagging() bool {
+	return true
+}
+
+func (r *MemoryReporter) Flush() {}
+
+func (r *MemoryReporter) ReportCounter(name string, tags map[string]string, value int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(r.counters, name, tags)
+	s.counter += value
+}
+
+func (r *MemoryReporter) ReportGauge(name string, tags map[string]string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(r.gauges, name, tags)
+	r.gaugeSeq++
+	s.gauge = value
+	s.gaugeSeq = r.gaugeSeq
+}
+
+func (r *MemoryReporter) ReportTimer(name string, tags map[string]string, interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(r.timers, name, tags)
+	s.timers = append(s.timers, interval)
+}
+
+func (r *MemoryReporter) ReportHistogramValueSamples(name string, tags map[string]string, buckets tally.Buckets, bucketLowerBound, bucketUpperBound float64, samples int64) {
+	r.reportHistogram(name, tags, bucketUpperBound, samples)
+}
+
+func (r *MemoryReporter) ReportHistogramDurationSamples(name string, tags map[string]string, buckets tally.Buckets, bucketLowerBound, bucketUpperBound time.Duration, samples int64) {
+	upper := math.Inf(1)
+	if bucketUpperBound != time.Duration(math.MaxInt64) {
+		upper = bucketUpperBound.Seconds()
+	}
+	r.reportHistogram(name, tags, upper, samples)
+}
+
+func (r *MemoryReporter) reportHistogram(name string, tags map[string]string, upper float64, samples int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(r.histograms, name, tags)
+	if s.histogram == nil {
+		s.histogram = make(map[float64]int64)
+	}
+	s.histogram[upper] += samples
+}
+
+// matching returns the series of name whose tags contain tags.
+func matching(m map[string]*memorySeries, name string, tags map[string]string) []*memorySeries {
+	var out []*memorySeries
+	for _, s := range m {
+		if s.name != name || !containsTags(s.tags, tags) {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func containsTags(have, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := have[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
+// Counter returns the sum of the matching counters.
+func (r *MemoryReporter) Counter(name string, tags map[string]string) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var total int64
+	for _, s := range matching(r.counters, name, tags) {
+		total += s.counter
+	}
+	return total
+}
+
+// Gauge returns the most recently updated matching gauge value.
+func (r *MemoryReporter) Gauge(name string, tags map[string]string) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var last *memorySeries
+	for _, s := range matching(r.gauges, name, tags) {
+		if last == nil || s.gaugeSeq > last.gaugeSeq {
+			last = s
+		}
+	}
+	if last == nil {
+		return 0, false
+	}
+	return last.gauge, true
+}
+
+// Timers returns every matching timer recording.
+func (r *MemoryReporter) Timers(name string, tags map[string]string) []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var out []time.Duration
+	for _, s := range matching(r.timers, name, tags) {
+		out = append(out, s.timers...)
+	}
+	return out
+}
+
+// HistogramSamples returns the number of matching histogram samples per bucket
+// upper bound. Duration buckets are expressed in seconds.
+func (r *MemoryReporter) HistogramSamples(name string, tags map[string]string) map[float64]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	out := make(map[float64]int64)
+	for _, s := range matching(r.histograms, name, tags) {
+		for upper, samples := range s.histogram {
+			out[upper] += samples
+		}
+	}
+	return out
+}
+
+// AssertCounter fails t unless the matching counters sum to want.
+func (r *MemoryReporter) AssertCounter(t TB, name string, tags map[string]string, want int64) {
+	t.Helper()
+	if got := r.Counter(name, tags); got != want {
+		t.Errorf("counter %s%v = %d, want %d", name, tags, got, want)
+	}
+}
+
+// AssertGauge fails t unless the last matching gauge update was want.
+func (r *MemoryReporter) AssertGauge(t TB, name string, tags map[string]string, want float64) {
+	t.Helper()
+	got, ok := r.Gauge(name, tags)
+	if !ok {
+		t.Errorf("gauge %s%v was never updated, want %v", name, tags, want)
+		return
+	}
+	if got != want {
+		t.Errorf("gauge %s%v = %v, want %v", name, tags, got, want)
+	}
+}
+
+// AssertTimerCount fails t unless the matching timers were recorded want times.
+func (r *MemoryReporter) AssertTimerCount(t TB, name string, tags map[string]string, want int) {
+	t.Helper()
+	if got := len(r.Timers(name, tags)); got != want {
+		t.Errorf("timer %s%v recorded %d times, want %d", name, tags, got, want)
+	}
+}
+
+// AssertHistogramCount fails t unless the matching histograms hold want samples.
+func (r *MemoryReporter) AssertHistogramCount(t TB, name string, tags map[string]string, want int64) {
+	t.Helper()
+	var got int64
+	for _, samples := range r.HistogramSamples(name, tags) {
+		got += samples
+	}
+	if got != want {
+		t.Errorf("histogram %s%v has %d samples, want %d", name, tags, got, want)
+	}
+}
+
+// memoryHandler is the client.MetricsHandler returned by
+// MemoryReporter.MetricsHandler.
+type memoryHandler struct {
+	reporter *MemoryReporter
+	tags     map[string]string
+}
+
+func (h memoryHandler) WithTags(tags map[string]string) client.MetricsHandler {
+	merged := make(map[string]string, len(h.tags)+len(tags))
+	for k, v := range h.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return memoryHandler{reporter: h.reporter, tags: merged}
+}
+
+func (h memoryHandler) Counter(name string) client.MetricsCounter {
+	return memoryMetric{handler: h, name: name}
+}
+
+func (h memoryHandler) Gauge(name string) client.MetricsGauge {
+	return memoryMetric{handler: h, name: name}
+}
+
+func (h memoryHandler) Timer(name string) client.MetricsTimer {
+	return memoryMetric{handler: h, name: name}
+}
+
+// memoryMetric implements the SDK counter, gauge and timer interfaces.
+type memoryMetric struct {
+	handler memoryHandler
+	name    string
+}
+
+func (m memoryMetric) Inc(value int64) {
+	m.handler.reporter.ReportCounter(m.name, m.handler.tags, value)
+}
+
+func (m memoryMetric) Update(value float64) {
+	m.handler.reporter.ReportGauge(m.name, m.handler.tags, value)
+}
+
+func (m memoryMetric) Record(d time.Duration) {
+	m.handler.reporter.ReportTimer(m.name, m.handler.tags, d)
+}
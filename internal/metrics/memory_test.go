@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/uber-go/tally/v4"
+)
+
+func TestMemoryReporterTallyScope(t *testing.T) {
+	reporter := NewMemoryReporter()
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{Reporter: reporter}, 0)
+
+	tagged := scope.Tagged(map[string]string{"provider": "ip-api"})
+	tagged.Counter("lookups").Inc(2)
+	scope.Tagged(map[string]string{"provider": "icanhazip"}).Counter("lookups").Inc(1)
+	tagged.Gauge("inflight").Update(3)
+	tagged.Timer("latency").Record(250 * time.Millisecond)
+	tagged.Histogram("size", tally.ValueBuckets{10, 100}).RecordValue(42)
+
+	// Closing the root scope reports everything buffered so far
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reporter.AssertCounter(t, "lookups", map[string]string{"provider": "ip-api"}, 2)
+	reporter.AssertCounter(t, "lookups", nil, 3)
+	reporter.AssertGauge(t, "inflight", map[string]string{"provider": "ip-api"}, 3)
+	reporter.AssertTimerCount(t, "latency", nil, 1)
+	reporter.AssertHistogramCount(t, "size", nil, 1)
+
+	if got := reporter.HistogramSamples("size", nil)[100]; got != 1 {
+		t.Errorf("size samples in bucket 100 = %d, want 1", got)
+	}
+}
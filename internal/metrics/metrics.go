@@ -1,29 +1,63 @@
 package metrics
 
 import (
+	"context"
+	"net/http"
+	"sync"
+
 	"github.com/natemollica-nm/temporal/internal/config"
 	"github.com/uber-go/tally/v4"
+	"go.temporal.io/sdk/client"
+	sdktally "go.temporal.io/sdk/contrib/tally"
 	sdklog "go.temporal.io/sdk/log"
 )
 
-var globalScope tally.Scope
+// Provider owns a root metrics scope and the reporters behind it. Create one
+// per process (or per test) and pass it to whatever emits metrics.
+type Provider struct {
+	factory *Factory
+	scope   tally.Scope
+	handler client.MetricsHandler
+
+	closeOnce sync.Once
+	closeErr  error
+}
 
-// Initialize sets up the global metrics scope based on configuration
-func Initialize(cfg config.MetricsConfig, logger sdklog.Logger) error {
-	factory := NewFactory(cfg, logger)
+// NewProvider creates the scope and reporters for the configured provider
+func NewProvider(cfg config.MetricsConfig, logger sdklog.Logger, opts ...FactoryOption) (*Provider, error) {
+	factory := NewFactory(cfg, logger, opts...)
 	scope, err := factory.CreateScope()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	globalScope = scope
-	return nil
+	return &Provider{
+		factory: factory,
+		scope:   scope,
+		handler: sdktally.NewMetricsHandler(scope),
+	}, nil
 }
 
-// GetScope returns the global metrics scope
-func GetScope() tally.Scope {
-	if globalScope == nil {
-		// Fallback to noop scope if not initialized
-		return tally.NoopScope
-	}
-	return globalScope
+// Scope returns the root tally scope
+func (p *Provider) Scope() tally.Scope {
+	return p.scope
+}
+
+// MetricsHandler returns the Temporal SDK metrics handler backed by the scope
+func (p *Provider) MetricsHandler() client.MetricsHandler {
+	return p.handler
+}
+
+// HTTPHandler returns the handler exposing the metrics for scraping, or nil
+// if the configured provider is not scraped over HTTP.
+func (p *Provider) HTTPHandler() http.Handler {
+	return p.factory.Handler()
+}
+
+// Close flushes the final metrics and releases the reporters. It should be
+// called before the process exits; calls after the first are no-ops.
+func (p *Provider) Close(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		p.closeErr = p.factory.Close(ctx)
+	})
+	return p.closeErr
 }
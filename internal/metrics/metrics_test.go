@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+)
+
+func newTestProvider(t *testing.T) *Provider {
+	t.Helper()
+
+	cfg := config.Default().Metrics
+	cfg.Provider = "prometheus"
+	cfg.Prometheus.ListenAddress = ""
+
+	provider, err := NewProvider(cfg, nil)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return provider
+}
+
+func scrape(t *testing.T, provider *Provider) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	provider.HTTPHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("read scrape: %v", err)
+	}
+	return string(body)
+}
+
+func TestProvidersAreIsolated(t *testing.T) {
+	first := newTestProvider(t)
+	second := newTestProvider(t)
+
+	first.MetricsHandler().Counter("first_only").Inc(1)
+	second.MetricsHandler().Counter("second_only").Inc(1)
+
+	// Close flushes the root scopes into their registries
+	for _, p := range []*Provider{first, second} {
+		if err := p.Close(context.Background()); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	firstBody, secondBody := scrape(t, first), scrape(t, second)
+	if !strings.Contains(firstBody, "temporal_samples_first_only_total 1") || strings.Contains(firstBody, "second_only") {
+		t.Errorf("first provider exposes the wrong metrics:\n%s", firstBody)
+	}
+	if !strings.Contains(secondBody, "temporal_samples_second_only_total 1") || strings.Contains(secondBody, "first_only") {
+		t.Errorf("second provider exposes the wrong metrics:\n%s", secondBody)
+	}
+}
+
+func TestProviderCloseIsIdempotent(t *testing.T) {
+	provider := newTestProvider(t)
+	for i := 0; i < 2; i++ {
+		if err := provider.Close(context.Background()); err != nil {
+			t.Fatalf("Close #%d: %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/uber-go/tally/v4"
+	"github.com/uber-go/tally/v4/prometheus"
+	sdklog "go.temporal.io/sdk/log"
+)
+
+const defaultPrometheusHandlerPath = "/metrics"
+
+// prometheusOverride is a reporter dedicated to a single metric whose timer
+// type or buckets differ from the defaults.
+type prometheusOverride struct {
+	reporter prometheus.Reporter
+	buckets  tally.Buckets
+}
+
+// prometheusReporter routes timers and histograms that have per-metric
+// overrides to their own tally reporter. All reporters share one registry.
+type prometheusReporter struct {
+	prometheus.Reporter
+	overrides map[string]prometheusOverride
+}
+
+func newPrometheusReporter(cfg config.PrometheusConfig, registry *prom.Registry, onError func(error)) (*prometheusReporter, error) {
+	opts, err := prometheusReporterOptions(cfg.TimerType, cfg.HistogramBuckets, cfg.SummaryObjectives)
+	if err != nil {
+		return nil, err
+	}
+	opts.Registerer = registry
+	opts.OnRegisterError = onError
+
+	r := &prometheusReporter{
+		Reporter:  prometheus.NewReporter(opts),
+		overrides: make(map[string]prometheusOverride, len(cfg.Metrics)),
+	}
+
+	for name, metric := range cfg.Metrics {
+		timerType := metric.TimerType
+		if timerType == "" {
+			timerType = cfg.TimerType
+		}
+		opts, err := prometheusReporterOptions(timerType, metric.HistogramBuckets, metric.SummaryObjectives)
+		if err != nil {
+			return nil, fmt.Errorf("metric %q: %w", name, err)
+		}
+		opts.Registerer = registry
+		opts.OnRegisterError = onError
+
+		override := prometheusOverride{reporter: prometheus.NewReporter(opts)}
+		if len(metric.HistogramBuckets) > 0 {
+			override.buckets = tally.ValueBuckets(metric.HistogramBuckets)
+		}
+		r.overrides[name] = override
+	}
+
+	return r, nil
+}
+
+func prometheusReporterOptions(timerType string, buckets []float64, objectives map[float64]float64) (prometheus.Options, error) {
+	opts := prometheus.Options{
+		DefaultHistogramBuckets:  buckets,
+		DefaultSummaryObjectives: objectives,
+	}
+
+	switch timerType {
+	case "", "histogram":
+		opts.DefaultTimerType = prometheus.HistogramTimerType
+	case "summary":
+		opts.DefaultTimerType = prometheus.SummaryTimerType
+	default:
+		return opts, fmt.Errorf("unknown timer type %q", timerType)
+	}
+	return opts, nil
+}
+
+func (r *prometheusReporter) AllocateTimer(name string, tags map[string]string) tally.CachedTimer {
+	if override, ok := r.overrides[name]; ok {
+		return override.reporter.AllocateTimer(name, tags)
+	}
+	return r.Reporter.AllocateTimer(name, tags)
+}
+
+func (r *prometheusReporter) AllocateHistogram(name string, tags map[string]string, buckets tally.Buckets) tally.CachedHistogram {
+	if override, ok := r.overrides[name]; ok {
+		if override.buckets != nil {
+			buckets = override.buckets
+		}
+		return override.reporter.AllocateHistogram(name, tags, buckets)
+	}
+	return r.Reporter.AllocateHistogram(name, tags, buckets)
+}
+
+// registerRuntimeCollectors adds the Go runtime and process collectors to
+// registry. Collectors already present in a caller supplied registry are
+// left alone.
+func registerRuntimeCollectors(cfg config.PrometheusConfig, registry *prom.Registry) error {
+	var cs []prom.Collector
+	if cfg.GoCollector {
+		cs = append(cs, collectors.NewGoCollector())
+	}
+	if cfg.ProcessCollector {
+		cs = append(cs, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	}
+
+	for _, c := range cs {
+		if err := registry.Register(c); err != nil {
+			var are prom.AlreadyRegisteredError
+			if errors.As(err, &are) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// newPrometheusHandler returns the scrape handler for registry.
+func newPrometheusHandler(cfg config.PrometheusConfig, registry *prom.Registry) http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		EnableOpenMetrics: cfg.OpenMetrics,
+	})
+}
+
+// servePrometheus serves handler on a dedicated listener in the background
+// until the returned server is closed.
+func servePrometheus(cfg config.PrometheusConfig, handler http.Handler, logger sdklog.Logger) *http.Server {
+	path := cfg.HandlerPath
+	if path == "" {
+		path = defaultPrometheusHandlerPath
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		listener, err := net.Listen("tcp", cfg.ListenAddress)
+		if err != nil {
+			logger.Error("Prometheus listener error", "address", cfg.ListenAddress, "error", err)
+			return
+		}
+
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Prometheus listener error", "address", cfg.ListenAddress, "error", err)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/uber-go/tally/v4"
+)
+
+// gatherFamily returns the metric family called name from registry.
+func gatherFamily(t *testing.T, registry *prom.Registry, name string) *dto.MetricFamily {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family
+		}
+	}
+	t.Fatalf("metric %q was not registered", name)
+	return nil
+}
+
+func TestPrometheusReporterOverrides(t *testing.T) {
+	cfg := config.PrometheusConfig{
+		TimerType:        "histogram",
+		HistogramBuckets: []float64{1, 2},
+		Metrics: map[string]config.PrometheusMetricConfig{
+			"lookup_latency": {HistogramBuckets: []float64{0.1, 0.5, 1, 5}},
+			"store_latency":  {TimerType: "summary", SummaryObjectives: map[float64]float64{0.5: 0.05, 0.99: 0.001}},
+			"payload_size":   {HistogramBuckets: []float64{100, 1000}},
+		},
+	}
+
+	registry := prom.NewRegistry()
+	reporter, err := newPrometheusReporter(cfg, registry, func(err error) { t.Errorf("register: %v", err) })
+	if err != nil {
+		t.Fatalf("newPrometheusReporter: %v", err)
+	}
+	scope, closer := tally.NewRootScope(tally.ScopeOptions{
+		CachedReporter:         reporter,
+		OmitCardinalityMetrics: true,
+	}, time.Hour)
+
+	scope.Timer("lookup_latency").Record(300 * time.Millisecond)
+	scope.Timer("store_latency").Record(300 * time.Millisecond)
+	scope.Timer("other_latency").Record(300 * time.Millisecond)
+	scope.Histogram("payload_size", tally.ValueBuckets{10, 20}).RecordValue(500)
+	if err := closer.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	histogramBounds := func(name string) []float64 {
+		family := gatherFamily(t, registry, name)
+		if family.GetType() != dto.MetricType_HISTOGRAM {
+			t.Fatalf("%s type = %v, want histogram", name, family.GetType())
+		}
+		var bounds []float64
+		for _, bucket := range family.GetMetric()[0].GetHistogram().GetBucket() {
+			bounds = append(bounds, bucket.GetUpperBound())
+		}
+		return bounds
+	}
+
+	tests := []struct {
+		name string
+		want []float64
+	}{
+		{name: "lookup_latency", want: []float64{0.1, 0.5, 1, 5}},
+		{name: "other_latency", want: []float64{1, 2}},
+		// The configured buckets win over the ones the caller asked for
+		{name: "payload_size", want: []float64{100, 1000}},
+	}
+	for _, tt := range tests {
+		if got := histogramBounds(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s buckets = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	summary := gatherFamily(t, registry, "store_latency")
+	if summary.GetType() != dto.MetricType_SUMMARY {
+		t.Fatalf("store_latency type = %v, want summary", summary.GetType())
+	}
+	var quantiles []float64
+	for _, q := range summary.GetMetric()[0].GetSummary().GetQuantile() {
+		quantiles = append(quantiles, q.GetQuantile())
+	}
+	if !reflect.DeepEqual(quantiles, []float64{0.5, 0.99}) {
+		t.Errorf("store_latency quantiles = %v, want [0.5 0.99]", quantiles)
+	}
+}
+
+func TestPrometheusReporterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.PrometheusConfig
+		wantErr string
+	}{
+		{name: "timer type", cfg: config.PrometheusConfig{TimerType: "gauge"}, wantErr: `unknown timer type "gauge"`},
+		{
+			name: "per-metric timer type",
+			cfg: config.PrometheusConfig{Metrics: map[string]config.PrometheusMetricConfig{
+				"lookup_latency": {TimerType: "gauge"},
+			}},
+			wantErr: `metric "lookup_latency"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPrometheusReporter(tt.cfg, prom.NewRegistry(), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRegisterRuntimeCollectors(t *testing.T) {
+	registry := prom.NewRegistry()
+	cfg := config.PrometheusConfig{GoCollector: true, ProcessCollector: true}
+
+	// A second registration, as with a caller supplied registry, is not an error
+	for range 2 {
+		if err := registerRuntimeCollectors(cfg, registry); err != nil {
+			t.Fatalf("registerRuntimeCollectors: %v", err)
+		}
+	}
+
+	gatherFamily(t, registry, "go_goroutines")
+	gatherFamily(t, registry, "process_start_time_seconds")
+
+	empty := prom.NewRegistry()
+	if err := registerRuntimeCollectors(config.PrometheusConfig{}, empty); err != nil {
+		t.Fatalf("registerRuntimeCollectors: %v", err)
+	}
+	if families, _ := empty.Gather(); len(families) != 0 {
+		t.Errorf("registered %d families with the collectors off", len(families))
+	}
+}
+
+func TestServePrometheus(t *testing.T) {
+	// Reserve a free port for the listener
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	registry := prom.NewRegistry()
+	counter := prom.NewCounter(prom.CounterOpts{Name: "lookups_total", Help: "Lookups."})
+	registry.MustRegister(counter)
+	counter.Inc()
+
+	cfg := config.PrometheusConfig{ListenAddress: address, HandlerPath: "/internal/metrics"}
+	server := servePrometheus(cfg, newPrometheusHandler(cfg, registry), defaultLogger())
+	defer server.Close()
+
+	var resp *http.Response
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if resp, err = http.Get("http://" + address + cfg.HandlerPath); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("scrape: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "lookups_total 1") {
+		t.Errorf("scrape = %d %q", resp.StatusCode, body)
+	}
+
+	resp, err = http.Get("http://" + address + defaultPrometheusHandlerPath)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("default path status = %d, want 404 when the path is configured", resp.StatusCode)
+	}
+}
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	sdklog "go.temporal.io/sdk/log"
+)
+
+const (
+	defaultPushJob            = "temporal_samples"
+	defaultPushInterval       = 15 * time.Second
+	defaultPushTimeout        = 10 * time.Second
+	defaultPushInitialBackoff = 500 * time.Millisecond
+	defaultPushMaxBackoff     = 10 * time.Second
+)
+
+// pushgatewayPusher periodically pushes a registry to a Prometheus Pushgateway
+// so that metrics of short-lived processes survive until they are scraped.
+type pushgatewayPusher struct {
+	pusher         *push.Pusher
+	logger         sdklog.Logger
+	interval       time.Duration
+	timeout        time.Duration
+	maxRetries     int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+
+	// status is the status code of the last push response, 0 when the push
+	// got no response
+	status atomic.Int32
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func newPushgatewayPusher(cfg config.PushgatewayConfig, registry *prom.Registry, logger sdklog.Logger) (*pushgatewayPusher, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("pushgateway URL is required")
+	}
+
+	job := cfg.Job
+	if job == "" {
+		job = defaultPushJob
+	}
+
+	p := &pushgatewayPusher{
+		logger:         logger,
+		interval:       cfg.PushInterval,
+		timeout:        cfg.Timeout,
+		maxRetries:     cfg.MaxRetries,
+		initialBackoff: cfg.InitialBackoff,
+		maxBackoff:     cfg.MaxBackoff,
+	}
+
+	pusher := push.New(cfg.URL, job).
+		Gatherer(registry).
+		Client(&http.Client{Transport: statusTransport{status: &p.status}})
+	if cfg.Instance != "" {
+		pusher = pusher.Grouping("instance", cfg.Instance)
+	}
+	if cfg.BuildID != "" {
+		pusher = pusher.Grouping("build_id", cfg.BuildID)
+	}
+	for name, value := range cfg.Grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	if err := pusher.Error(); err != nil {
+		return nil, err
+	}
+
+	p.pusher = pusher
+	if p.interval <= 0 {
+		p.interval = defaultPushInterval
+	}
+	if p.timeout <= 0 {
+		p.timeout = defaultPushTimeout
+	}
+	if p.initialBackoff <= 0 {
+		p.initialBackoff = defaultPushInitialBackoff
+	}
+	if p.maxBackoff <= 0 {
+		p.maxBackoff = defaultPushMaxBackoff
+	}
+	return p, nil
+}
+
+// Start pushes the registry every interval until Close is called.
+func (p *pushgatewayPusher) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.Push(ctx); err != nil && ctx.Err() == nil {
+					p.logger.Error("Pushgateway push failed", "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the periodic pushes and pushes the registry one last time.
+func (p *pushgatewayPusher) Close(ctx context.Context) error {
+	if p.cancel != nil {
+		p.cancel()
+		select {
+		case <-p.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return p.Push(ctx)
+}
+
+// Push replaces the metrics of this grouping key on the Pushgateway, retrying
+// network errors, 5xx, 408 and 429 responses with exponential backoff. Other
+// failures, such as a 400 for a malformed grouping key, are returned at once.
+func (p *pushgatewayPusher) Push(ctx context.Context) error {
+	backoff := p.initialBackoff
+	for attempt := 0; ; attempt++ {
+		p.status.Store(0)
+		pushCtx, cancel := context.WithTimeout(ctx, p.timeout)
+		err := p.pusher.PushContext(pushCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if !retryablePush(err, int(p.status.Load())) {
+			return fmt.Errorf("push failed: %w", err)
+		}
+		if attempt >= p.maxRetries {
+			return fmt.Errorf("push failed after %d attempts: %w", attempt+1, err)
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		}
+		backoff = min(backoff*2, p.maxBackoff)
+	}
+}
+
+// retryablePush reports whether a push that failed with err and got a
+// response with status (0 for none) is worth retrying
+func retryablePush(err error, status int) bool {
+	if status == 0 {
+		// Only failures to reach the Pushgateway; gathering errors repeat
+		var urlErr *url.Error
+		return errors.As(err, &urlErr)
+	}
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
+
+// statusTransport records the status code of every response it returns
+type statusTransport struct {
+	status *atomic.Int32
+}
+
+func (t statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		t.status.Store(int32(resp.StatusCode))
+	}
+	return resp, err
+}
@@ -0,0 +1,198 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+type pushRequest struct {
+	method string
+	path   string
+	body   string
+}
+
+// fakePushgateway records pushes and fails the first failures of them with
+// status, 503 by default.
+type fakePushgateway struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	requests []pushRequest
+}
+
+func (g *fakePushgateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.requests = append(g.requests, pushRequest{method: r.Method, path: r.URL.Path, body: string(body)})
+	if g.failures > 0 {
+		g.failures--
+		status := g.status
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (g *fakePushgateway) last(t *testing.T) pushRequest {
+	t.Helper()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if len(g.requests) == 0 {
+		t.Fatal("no pushes received")
+	}
+	return g.requests[len(g.requests)-1]
+}
+
+// groupingLabels parses /metrics/job/<job>/<label>/<value>... into a map.
+func groupingLabels(t *testing.T, path string) map[string]string {
+	t.Helper()
+
+	parts := strings.Split(strings.TrimPrefix(path, "/metrics/"), "/")
+	if len(parts)%2 != 0 {
+		t.Fatalf("malformed push path %q", path)
+	}
+	labels := make(map[string]string, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		labels[parts[i]] = parts[i+1]
+	}
+	return labels
+}
+
+func testPushgatewayConfig(url string) config.PushgatewayConfig {
+	return config.PushgatewayConfig{
+		URL:            url,
+		Job:            "ci_worker",
+		Instance:       "runner-1",
+		BuildID:        "1234",
+		Grouping:       map[string]string{"branch": "main"},
+		PushInterval:   time.Hour,
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	}
+}
+
+func TestPushgatewayPusherRetries(t *testing.T) {
+	gateway := &fakePushgateway{failures: 2}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	registry := prom.NewRegistry()
+	counter := prom.NewCounter(prom.CounterOpts{Name: "lookups_total"})
+	registry.MustRegister(counter)
+	counter.Add(3)
+
+	pusher, err := newPushgatewayPusher(testPushgatewayConfig(server.URL), registry, defaultLogger())
+	if err != nil {
+		t.Fatalf("newPushgatewayPusher: %v", err)
+	}
+	if err := pusher.Push(context.Background()); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	if got := len(gateway.requests); got != 3 {
+		t.Fatalf("got %d requests, want 3", got)
+	}
+
+	req := gateway.last(t)
+	if req.method != http.MethodPut {
+		t.Errorf("method = %s, want PUT", req.method)
+	}
+	want := map[string]string{"job": "ci_worker", "instance": "runner-1", "build_id": "1234", "branch": "main"}
+	got := groupingLabels(t, req.path)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("grouping label %s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestPushgatewayPusherGivesUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantRequests int
+	}{
+		{name: "server error", status: http.StatusBadGateway, wantRequests: 4},
+		{name: "rate limited", status: http.StatusTooManyRequests, wantRequests: 4},
+		{name: "bad request", status: http.StatusBadRequest, wantRequests: 1},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := &fakePushgateway{failures: 10, status: tt.status}
+			server := httptest.NewServer(gateway)
+			defer server.Close()
+
+			pusher, err := newPushgatewayPusher(testPushgatewayConfig(server.URL), prom.NewRegistry(), defaultLogger())
+			if err != nil {
+				t.Fatalf("newPushgatewayPusher: %v", err)
+			}
+			if err := pusher.Push(context.Background()); err == nil {
+				t.Fatal("Push succeeded, want error")
+			}
+			if got := len(gateway.requests); got != tt.wantRequests {
+				t.Fatalf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestPushgatewayPusherUnreachable(t *testing.T) {
+	server := httptest.NewServer(&fakePushgateway{})
+	server.Close()
+
+	pusher, err := newPushgatewayPusher(testPushgatewayConfig(server.URL), prom.NewRegistry(), defaultLogger())
+	if err != nil {
+		t.Fatalf("newPushgatewayPusher: %v", err)
+	}
+	err = pusher.Push(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "after 4 attempts") {
+		t.Fatalf("err = %v, want the push retried", err)
+	}
+}
+
+func TestPushgatewayProviderPushesOnClose(t *testing.T) {
+	gateway := &fakePushgateway{}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	cfg := config.Default().Metrics
+	cfg.Provider = "prometheus-push"
+	cfg.Pushgateway = testPushgatewayConfig(server.URL)
+
+	factory := NewFactory(cfg, nil)
+	scope, err := factory.CreateScope()
+	if err != nil {
+		t.Fatalf("CreateScope: %v", err)
+	}
+	scope.Counter("activity_started").Inc(2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := factory.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if body := gateway.last(t).body; !strings.Contains(body, "temporal_samples_activity_started_total") {
+		t.Fatalf("final push does not contain the counter:\n%s", body)
+	}
+}
@@ -0,0 +1,148 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	temporalotel "go.temporal.io/sdk/contrib/opentelemetry"
+	"go.temporal.io/sdk/interceptor"
+)
+
+// Provider owns the OpenTelemetry tracer provider for a process and hands out
+// the instrumentation that uses it. Trace context is propagated with the W3C
+// traceparent and baggage headers, both over HTTP and in workflow headers.
+type Provider struct {
+	tracerProvider *sdktrace.TracerProvider
+	propagator     propagation.TextMapPropagator
+}
+
+// NewProvider creates a tracer provider exporting to the configured exporter.
+// serviceName is used when the config does not set one. Extra options, such as
+// a span processor in tests, are applied after the configured ones.
+func NewProvider(ctx context.Context, cfg config.TracingConfig, serviceName string, opts ...sdktrace.TracerProviderOption) (*Provider, error) {
+	if cfg.ServiceName != "" {
+		serviceName = cfg.ServiceName
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	}
+
+	exporter, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if exporter != nil {
+		tpOpts = append(tpOpts, sdktrace.WithBatcher(exporter))
+	}
+
+	return &Provider{
+		tracerProvider: sdktrace.NewTracerProvider(append(tpOpts, opts...)...),
+		propagator:     propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}),
+	}, nil
+}
+
+func newExporter(ctx context.Context, cfg config.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "", "none":
+		return nil, nil
+	case "stdout":
+		return stdouttrace.New()
+	case "otlp":
+		return newOTLPExporter(ctx, cfg.OTLP)
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+}
+
+func newOTLPExporter(ctx context.Context, cfg config.OTLPConfig) (sdktrace.SpanExporter, error) {
+	switch cfg.Protocol {
+	case "", "grpc":
+		var opts []otlptracegrpc.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracegrpc.WithHeaders(cfg.Headers))
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case "http":
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q", cfg.Protocol)
+	}
+}
+
+// Tracer returns a named tracer from the provider
+func (p *Provider) Tracer(name string) trace.Tracer {
+	return p.tracerProvider.Tracer(name)
+}
+
+// Middleware wraps handler so every request starts (or continues) a trace
+func (p *Provider) Middleware(handler http.Handler, operation string) http.Handler {
+	return otelhttp.NewHandler(handler, operation,
+		otelhttp.WithTracerProvider(p.tracerProvider),
+		otelhttp.WithPropagators(p.propagator),
+	)
+}
+
+// Transport wraps base so outbound requests are traced and carry the trace
+// context of their request's context. A nil base uses http.DefaultTransport.
+func (p *Provider) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base,
+		otelhttp.WithTracerProvider(p.tracerProvider),
+		otelhttp.WithPropagators(p.propagator),
+	)
+}
+
+// Interceptor returns the Temporal SDK tracing interceptor, which propagates
+// the trace through workflow and activity headers.
+func (p *Provider) Interceptor() (interceptor.Interceptor, error) {
+	return temporalotel.NewTracingInterceptor(temporalotel.TracerOptions{
+		Tracer:            p.Tracer("temporal-sdk-go"),
+		TextMapPropagator: p.propagator,
+	})
+}
+
+// Shutdown flushes pending spans and stops the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return p.tracerProvider.Shutdown(ctx)
+}
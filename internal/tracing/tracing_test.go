@@ -0,0 +1,118 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/config"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func newTestProvider(t *testing.T) (*Provider, *tracetest.SpanRecorder) {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider, err := NewProvider(context.Background(), config.TracingConfig{Exporter: "none"}, "test",
+		sdktrace.WithSpanProcessor(recorder))
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+	return provider, recorder
+}
+
+func TestMiddlewareAndTransportShareTrace(t *testing.T) {
+	provider, recorder := newTestProvider(t)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer upstream.Close()
+
+	httpClient := &http.Client{Transport: provider.Transport(nil)}
+	handler := provider.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, upstream.URL, nil)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			t.Errorf("outbound request: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}), "api")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	client, server := spans[0], spans[1]
+	if client.SpanKind() != trace.SpanKindClient || server.SpanKind() != trace.SpanKindServer {
+		t.Fatalf("unexpected span kinds %v, %v", client.SpanKind(), server.SpanKind())
+	}
+	if client.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("outbound span is not a child of the server span")
+	}
+	if traceparent == "" {
+		t.Error("traceparent header was not propagated")
+	}
+}
+
+func tracedActivity(ctx context.Context) (string, error) {
+	return trace.SpanContextFromContext(ctx).TraceID().String(), nil
+}
+
+func tracedWorkflow(ctx workflow.Context) (string, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+	var traceID string
+	err := workflow.ExecuteActivity(ctx, tracedActivity).Get(ctx, &traceID)
+	return traceID, err
+}
+
+func TestInterceptorPropagatesToActivities(t *testing.T) {
+	provider, recorder := newTestProvider(t)
+
+	tracingInterceptor, err := provider.Interceptor()
+	if err != nil {
+		t.Fatalf("Interceptor: %v", err)
+	}
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+	env.SetWorkerOptions(worker.Options{Interceptors: []interceptor.WorkerInterceptor{tracingInterceptor}})
+	env.RegisterWorkflow(tracedWorkflow)
+	env.RegisterActivity(tracedActivity)
+
+	env.ExecuteWorkflow(tracedWorkflow)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	var activityTraceID string
+	if err := env.GetWorkflowResult(&activityTraceID); err != nil {
+		t.Fatalf("GetWorkflowResult: %v", err)
+	}
+
+	var found bool
+	for _, span := range recorder.Ended() {
+		if span.Name() == "RunWorkflow:tracedWorkflow" {
+			found = true
+			if got := span.SpanContext().TraceID().String(); got != activityTraceID {
+				t.Errorf("activity trace %s differs from workflow trace %s", activityTraceID, got)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no workflow span recorded, got %d spans", len(recorder.Ended()))
+	}
+}
@@ -1,24 +1,44 @@
 package ip
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 )
 
+// HTTPGetter sends the activities' outbound requests. *http.Client satisfies
+// it; wrap its transport to trace the requests.
 type HTTPGetter interface {
-	Get(url string) (*http.Response, error)
+	Do(req *http.Request) (*http.Response, error)
 }
 
+// Provider names the geolocation service the lookups query.
+const Provider = "ip-api"
+
+// Application error types of failures retrying will not fix
+const (
+	// ipLookupFailedType marks lookups ip-api rejects
+	ipLookupFailedType = "IPLookupFailed"
+	// ipFileType marks IP files that cannot be read
+	ipFileType = "IPFileUnavailable"
+)
+
 type IPActivities struct {
 	HTTPClient HTTPGetter
+	// BatchDir is the directory ReadIPs may read files from; empty disables
+	// file references.
+	BatchDir string
 }
 
 type IPInfo struct {
@@ -35,6 +55,12 @@ type IPInfo struct {
 	Zip         string  `json:"zip"`
 	Lat         float64 `json:"lat"`
 	Lon         float64 `json:"lon"`
+	Message     string  `json:"message"`
+}
+
+// Location formats the city, region and country of the IP address.
+func (i IPInfo) Location() string {
+	return fmt.Sprintf("%s, %s, %s", i.City, i.RegionName, i.Country)
 }
 
 // GetIP fetches the public IP address.
@@ -53,27 +79,45 @@ func (i *IPActivities) GetIP(ctx context.Context, scheduledTime int64) (string,
 	}()
 
 	logger.Info("Getting IP address")
-	resp, err := i.HTTPClient.Get("https://icanhazip.com")
+	resp, err := i.get(ctx, "https://icanhazip.com")
 	if err != nil {
-		logger.Error("failed to obtain IP address err=", err)
+		logger.Error("Failed to obtain IP address", "error", err)
 		return "", err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		logger.Error("failed to read IP address response body err=", err)
+		logger.Error("Failed to read IP address response body", "error", err)
 		return "", err
 	}
 
 	ip := strings.TrimSpace(string(body))
-	logger.Info("Got IP address", "ip=", ip)
+	logger.Info("Got IP address", "ip", ip)
 	return ip, nil
 }
 
-func (i *IPActivities) retrieveIPAddressInfo(ip string) (IPInfo, error) {
+// get issues a GET request carrying ctx, so outbound calls join the
+// activity's trace.
+func (i *IPActivities) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := i.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected response from %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+func (i *IPActivities) retrieveIPAddressInfo(ctx context.Context, ip string) (IPInfo, error) {
 	url := fmt.Sprintf("http://ip-api.com/json/%s", ip)
-	resp, err := i.HTTPClient.Get(url)
+	resp, err := i.get(ctx, url)
 	if err != nil {
 		return IPInfo{}, err
 	}
@@ -87,7 +131,13 @@ func (i *IPActivities) retrieveIPAddressInfo(ip string) (IPInfo, error) {
 	var data IPInfo
 	err = json.Unmarshal(body, &data)
 	if err != nil {
-		return IPInfo{}, err
+		return IPInfo{}, fmt.Errorf("failed to decode ip-api response: %w", err)
+	}
+	// ip-api answers lookups it cannot serve (private or reserved ranges,
+	// invalid queries) with status "fail". Retrying will not change that.
+	if data.Status == "fail" {
+		return IPInfo{}, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("ip-api lookup of %q failed: %s", ip, data.Message), ipLookupFailedType, nil)
 	}
 	return data, nil
 }
@@ -107,11 +157,30 @@ func (i *IPActivities) GetLocationInfo(ctx context.Context, ip string, scheduled
 		logger.Info("GetLocationInfo activity completed")
 	}()
 
-	info, err := i.retrieveIPAddressInfo(ip)
+	info, err := i.retrieveIPAddressInfo(ctx, ip)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s, %s, %s", info.City, info.RegionName, info.Country), nil
+	return info.Location(), nil
+}
+
+// GetIPInfo returns everything ip-api knows about the IP address.
+func (i *IPActivities) GetIPInfo(ctx context.Context, ip string, scheduledTime int64) (IPInfo, error) {
+	logger := activity.GetLogger(ctx)
+
+	var err error
+	metricsHandler := activity.GetMetricsHandler(ctx).WithTags(map[string]string{
+		"stage": "GetIPInfo",
+	})
+	metricsHandler = shared.RecordActivityStart(metricsHandler, "activity.get_ip_info", scheduledTime)
+	startTime := time.Now()
+	defer func() {
+		shared.RecordActivityEnd(metricsHandler, startTime, err)
+		logger.Info("GetIPInfo activity completed")
+	}()
+
+	info, err := i.retrieveIPAddressInfo(ctx, ip)
+	return info, err
 }
 
 func (i *IPActivities) GetInternetServiceProvider(ctx context.Context, ip string, scheduledTime int64) (string, error) {
@@ -128,9 +197,75 @@ func (i *IPActivities) GetInternetServiceProvider(ctx context.Context, ip string
 		logger.Info("GetInternetServiceProvider activity completed")
 	}()
 
-	info, err := i.retrieveIPAddressInfo(ip)
+	info, err := i.retrieveIPAddressInfo(ctx, ip)
 	if err != nil {
 		return "", err
 	}
 	return fmt.Sprintf("%s", info.ISP), nil
 }
+
+// IPPage is a page of IP addresses read from a file.
+type IPPage struct {
+	IPs []string `json:"ips"`
+	// NextOffset is the byte offset the next page starts at
+	NextOffset int64 `json:"nextOffset"`
+	EOF        bool  `json:"eof"`
+}
+
+// ReadIPs reads up to limit IP addresses, one per line, from the file at path
+// relative to BatchDir, starting at byte offset. Blank lines and lines
+// starting with # are skipped. The addresses are returned as written; callers
+// validate them.
+func (i *IPActivities) ReadIPs(ctx context.Context, path string, offset int64, limit int, scheduledTime int64) (IPPage, error) {
+	logger := activity.GetLogger(ctx)
+
+	var err error
+	metricsHandler := activity.GetMetricsHandler(ctx).WithTags(map[string]string{
+		"stage": "ReadIPs",
+	})
+	metricsHandler = shared.RecordActivityStart(metricsHandler, "activity.read_ips", scheduledTime)
+	startTime := time.Now()
+	defer func() {
+		shared.RecordActivityEnd(metricsHandler, startTime, err)
+		logger.Info("ReadIPs activity completed")
+	}()
+
+	if i.BatchDir == "" {
+		err = temporal.NewNonRetryableApplicationError("reading IPs from files is disabled", ipFileType, nil)
+		return IPPage{}, err
+	}
+	// OpenInRoot refuses paths that leave BatchDir
+	f, err := os.OpenInRoot(i.BatchDir, path)
+	if err != nil {
+		err = temporal.NewNonRetryableApplicationError(fmt.Sprintf("failed to open IP file: %s", err), ipFileType, err)
+		return IPPage{}, err
+	}
+	defer f.Close()
+
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return IPPage{}, err
+	}
+
+	page := IPPage{NextOffset: offset}
+	r := bufio.NewReader(f)
+	for len(page.IPs) < limit {
+		var line string
+		line, err = r.ReadString('\n')
+		page.NextOffset += int64(len(line))
+		if errors.Is(err, io.EOF) {
+			err = nil
+			page.EOF = true
+		} else if err != nil {
+			return IPPage{}, err
+		}
+
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			page.IPs = append(page.IPs, line)
+		}
+		if page.EOF {
+			break
+		}
+	}
+	logger.Info("Read IP addresses", "path", path, "count", len(page.IPs), "eof", page.EOF)
+	return page, nil
+}
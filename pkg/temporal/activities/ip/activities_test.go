@@ -0,0 +1,262 @@
+package ip
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/metrics"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+const (
+	testIP          = "203.0.113.7"
+	icanhazipURL    = "https://icanhazip.com"
+	ipAPILookupURL  = "http://ip-api.com/json/" + testIP
+	ipAPISuccessful = `{"status":"success","city":"Austin","regionName":"Texas","country":"United States","countryCode":"US","isp":"Example Fiber","query":"203.0.113.7"}`
+)
+
+type fakeResponse struct {
+	status int
+	body   string
+	err    error
+}
+
+// fakeGetter answers requests by URL and records every URL it was asked for.
+type fakeGetter struct {
+	mu        sync.Mutex
+	responses map[string]fakeResponse
+	requests  []string
+}
+
+func (g *fakeGetter) Do(req *http.Request) (*http.Response, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	url := req.URL.String()
+	g.requests = append(g.requests, url)
+
+	r, ok := g.responses[url]
+	if !ok {
+		return nil, fmt.Errorf("unexpected request to %s", url)
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Body:       io.NopCloser(strings.NewReader(r.body)),
+		Request:    req,
+	}, nil
+}
+
+// newTestEnv returns an activity environment running activities backed by
+// getter, and the reporter receiving their metrics.
+func newTestEnv(getter HTTPGetter) (*testsuite.TestActivityEnvironment, *IPActivities, *metrics.MemoryReporter) {
+	reporter := metrics.NewMemoryReporter()
+
+	var suite testsuite.WorkflowTestSuite
+	suite.SetMetricsHandler(reporter.MetricsHandler())
+	env := suite.NewTestActivityEnvironment()
+
+	activities := &IPActivities{HTTPClient: getter}
+	env.RegisterActivity(activities)
+	return env, activities, reporter
+}
+
+func TestGetIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		response fakeResponse
+		want     string
+		wantErr  bool
+	}{
+		{name: "trims newline", response: fakeResponse{body: testIP + "\n"}, want: testIP},
+		{name: "server error", response: fakeResponse{status: http.StatusServiceUnavailable}, wantErr: true},
+		{name: "transport error", response: fakeResponse{err: errors.New("connection refused")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getter := &fakeGetter{responses: map[string]fakeResponse{icanhazipURL: tt.response}}
+			env, activities, reporter := newTestEnv(getter)
+
+			val, err := env.ExecuteActivity(activities.GetIP, time.Now().UnixNano())
+			tags := map[string]string{"stage": "GetIP"}
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("GetIP succeeded, want error")
+				}
+				reporter.AssertCounter(t, "activity_failed", tags, 1)
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetIP: %v", err)
+			}
+
+			var got string
+			if err := val.Get(&got); err != nil {
+				t.Fatalf("decode result: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GetIP = %q, want %q", got, tt.want)
+			}
+			reporter.AssertCounter(t, "activity_succeeded", tags, 1)
+		})
+	}
+}
+
+func TestLookupActivities(t *testing.T) {
+	tests := []struct {
+		name         string
+		response     fakeResponse
+		wantLocation string
+		wantISP      string
+		wantErr      string
+		nonRetryable bool
+	}{
+		{
+			name:         "success",
+			response:     fakeResponse{body: ipAPISuccessful},
+			wantLocation: "Austin, Texas, United States",
+			wantISP:      "Example Fiber",
+		},
+		{
+			name:     "malformed json",
+			response: fakeResponse{body: `{"status":"success","city":`},
+			wantErr:  "failed to decode ip-api response",
+		},
+		{
+			name:         "status fail",
+			response:     fakeResponse{body: `{"status":"fail","message":"reserved range","query":"203.0.113.7"}`},
+			wantErr:      "reserved range",
+			nonRetryable: true,
+		},
+		{
+			name:     "rate limited",
+			response: fakeResponse{status: http.StatusTooManyRequests},
+			wantErr:  "429",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getter := &fakeGetter{responses: map[string]fakeResponse{ipAPILookupURL: tt.response}}
+			env, activities, _ := newTestEnv(getter)
+
+			lookups := []struct {
+				fn   any
+				want string
+			}{
+				{fn: activities.GetLocationInfo, want: tt.wantLocation},
+				{fn: activities.GetInternetServiceProvider, want: tt.wantISP},
+			}
+			for _, lookup := range lookups {
+				val, err := env.ExecuteActivity(lookup.fn, testIP, time.Now().UnixNano())
+				if tt.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+						t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+					}
+					var appErr *temporal.ApplicationError
+					if errors.As(err, &appErr) && appErr.NonRetryable() != tt.nonRetryable {
+						t.Errorf("NonRetryable = %v, want %v", appErr.NonRetryable(), tt.nonRetryable)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("lookup: %v", err)
+				}
+
+				var got string
+				if err := val.Get(&got); err != nil {
+					t.Fatalf("decode result: %v", err)
+				}
+				if got != lookup.want {
+					t.Errorf("lookup = %q, want %q", got, lookup.want)
+				}
+			}
+
+			if len(getter.requests) != len(lookups) {
+				t.Errorf("got %d requests, want %d", len(getter.requests), len(lookups))
+			}
+		})
+	}
+}
+
+func TestGetIPInfo(t *testing.T) {
+	getter := &fakeGetter{responses: map[string]fakeResponse{ipAPILookupURL: {body: ipAPISuccessful}}}
+	env, activities, _ := newTestEnv(getter)
+
+	val, err := env.ExecuteActivity(activities.GetIPInfo, testIP, time.Now().UnixNano())
+	if err != nil {
+		t.Fatalf("GetIPInfo: %v", err)
+	}
+	var info IPInfo
+	if err := val.Get(&info); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if info.City != "Austin" || info.CountryCode != "US" || info.ISP != "Example Fiber" {
+		t.Errorf("GetIPInfo = %+v", info)
+	}
+}
+
+func TestReadIPs(t *testing.T) {
+	dir := t.TempDir()
+	content := "# addresses from the edge logs\n203.0.113.1\n\n203.0.113.2\n 203.0.113.3 \n203.0.113.4"
+	if err := os.WriteFile(filepath.Join(dir, "ips.txt"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	env, activities, _ := newTestEnv(&fakeGetter{})
+	activities.BatchDir = dir
+
+	read := func(path string, offset int64, limit int) (IPPage, error) {
+		t.Helper()
+		val, err := env.ExecuteActivity(activities.ReadIPs, path, offset, limit, time.Now().UnixNano())
+		if err != nil {
+			return IPPage{}, err
+		}
+		var page IPPage
+		if err := val.Get(&page); err != nil {
+			t.Fatalf("decode result: %v", err)
+		}
+		return page, nil
+	}
+
+	first, err := read("ips.txt", 0, 2)
+	if err != nil {
+		t.Fatalf("ReadIPs: %v", err)
+	}
+	if !slices.Equal(first.IPs, []string{"203.0.113.1", "203.0.113.2"}) || first.EOF {
+		t.Errorf("first page = %+v", first)
+	}
+
+	second, err := read("ips.txt", first.NextOffset, 2)
+	if err != nil {
+		t.Fatalf("ReadIPs: %v", err)
+	}
+	if !slices.Equal(second.IPs, []string{"203.0.113.3", "203.0.113.4"}) || !second.EOF {
+		t.Errorf("second page = %+v", second)
+	}
+
+	// Files outside BatchDir are refused without retrying
+	_, err = read("../ips.txt", 0, 2)
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || !appErr.NonRetryable() {
+		t.Errorf("err = %v, want a non-retryable error", err)
+	}
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// storeDisabledType marks calls to a worker without a record directory
+const storeDisabledType = "RecordStoreDisabled"
+
+// StoreActivities keep JSON records as files of a local directory, one per
+// key. Saving and deleting are idempotent, so both are safe to retry and to
+// use as each other's compensation.
+type StoreActivities struct {
+	// Dir holds the records; empty disables the store.
+	Dir string
+}
+
+// SaveRecord writes record under key, replacing an earlier one.
+func (s *StoreActivities) SaveRecord(ctx context.Context, key string, record json.RawMessage, scheduledTime int64) error {
+	logger := activity.GetLogger(ctx)
+
+	var err error
+	metricsHandler := activity.GetMetricsHandler(ctx).WithTags(map[string]string{
+		"stage": "SaveRecord",
+	})
+	metricsHandler = shared.RecordActivityStart(metricsHandler, "activity.save_record", scheduledTime)
+	startTime := time.Now()
+	defer func() {
+		shared.RecordActivityEnd(metricsHandler, startTime, err)
+		logger.Info("SaveRecord activity completed")
+	}()
+
+	root, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	// Write beside the record and rename, so readers never see half of it
+	name := fileName(key)
+	if err = root.WriteFile(name+".tmp", record, 0o644); err != nil {
+		return err
+	}
+	err = root.Rename(name+".tmp", name)
+	return err
+}
+
+// DeleteRecord removes the record under key. A missing record is not an
+// error.
+func (s *StoreActivities) DeleteRecord(ctx context.Context, key string, scheduledTime int64) error {
+	logger := activity.GetLogger(ctx)
+
+	var err error
+	metricsHandler := activity.GetMetricsHandler(ctx).WithTags(map[string]string{
+		"stage": "DeleteRecord",
+	})
+	metricsHandler = shared.RecordActivityStart(metricsHandler, "activity.delete_record", scheduledTime)
+	startTime := time.Now()
+	defer func() {
+		shared.RecordActivityEnd(metricsHandler, startTime, err)
+		logger.Info("DeleteRecord activity completed")
+	}()
+
+	root, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+
+	if err = root.Remove(fileName(key)); errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return err
+}
+
+// ReadRecord returns the record saved under key in dir, for readers outside
+// of a worker such as the server.
+func ReadRecord(dir, key string) (json.RawMessage, error) {
+	if dir == "" {
+		return nil, errors.New("the record store is disabled")
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+
+	return root.ReadFile(fileName(key))
+}
+
+func (s *StoreActivities) open() (*os.Root, error) {
+	if s.Dir == "" {
+		return nil, temporal.NewNonRetryableApplicationError("the record store is disabled", storeDisabledType, nil)
+	}
+	return os.OpenRoot(s.Dir)
+}
+
+// fileName escapes key, so any key names a file directly inside Dir
+func fileName(key string) string {
+	return url.PathEscape(key) + ".json"
+}
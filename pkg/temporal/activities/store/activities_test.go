@@ -0,0 +1,65 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func TestRecords(t *testing.T) {
+	dir := t.TempDir()
+	activities := &StoreActivities{Dir: dir}
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(activities)
+
+	// Keys are escaped into a single file name inside Dir
+	key := "../getAddressFromIP/42"
+	record := json.RawMessage(`{"ip":"203.0.113.7"}`)
+	if _, err := env.ExecuteActivity(activities.SaveRecord, key, record, time.Now().UnixNano()); err != nil {
+		t.Fatalf("SaveRecord: %v", err)
+	}
+	path := filepath.Join(dir, fileName(key))
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read record: %v", err)
+	}
+	if string(got) != string(record) {
+		t.Errorf("record = %s, want %s", got, record)
+	}
+
+	if got, err := ReadRecord(dir, key); err != nil || string(got) != string(record) {
+		t.Errorf("ReadRecord = %s, %v, want %s", got, err, record)
+	}
+
+	// Deleting twice succeeds, so compensations can be retried
+	for range 2 {
+		if _, err := env.ExecuteActivity(activities.DeleteRecord, key, time.Now().UnixNano()); err != nil {
+			t.Fatalf("DeleteRecord: %v", err)
+		}
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stat deleted record: %v", err)
+	}
+}
+
+func TestRecordsDisabled(t *testing.T) {
+	activities := &StoreActivities{}
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(activities)
+
+	_, err := env.ExecuteActivity(activities.SaveRecord, "key", json.RawMessage(`{}`), time.Now().UnixNano())
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || !appErr.NonRetryable() {
+		t.Fatalf("err = %v, want a non-retryable error", err)
+	}
+}
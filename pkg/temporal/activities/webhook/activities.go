@@ -0,0 +1,155 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+)
+
+// Headers of a notification
+const (
+	// EventHeader names the event the notification reports
+	EventHeader = "X-Event"
+	// IDHeader identifies the notification. Retries of a delivery carry the
+	// same ID, so receivers can drop the ones they already processed.
+	IDHeader = "X-Webhook-Id"
+	// TimestampHeader carries the Unix time, in seconds, the attempt was sent
+	TimestampHeader = "X-Webhook-Timestamp"
+	// SignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+	// timestamp, a dot and the body, keyed with the shared secret
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// Application error types of deliveries retrying will not fix
+const (
+	// webhookRejectedType marks notifications the receiver refused with a
+	// 4xx status other than 408 and 429
+	webhookRejectedType = "WebhookRejected"
+	// webhookURLType marks callback URLs no request can be sent to
+	webhookURLType = "InvalidWebhookURL"
+)
+
+// HTTPDoer sends the notifications. *http.Client satisfies it; wrap its
+// transport to trace the requests.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type WebhookActivities struct {
+	HTTPClient HTTPDoer
+	// Secret signs the notifications; empty sends them unsigned.
+	Secret []byte
+}
+
+// NotifyWebhook POSTs payload to url as JSON, signed with Secret. Network
+// errors, 5xx, 408 and 429 responses are retried, honouring Retry-After;
+// other responses outside 2xx fail without retrying.
+func (a *WebhookActivities) NotifyWebhook(ctx context.Context, url, event string, payload json.RawMessage, scheduledTime int64) error {
+	logger := activity.GetLogger(ctx)
+
+	var err error
+	metricsHandler := activity.GetMetricsHandler(ctx).WithTags(map[string]string{
+		"stage": "NotifyWebhook",
+	})
+	metricsHandler = shared.RecordActivityStart(metricsHandler, "activity.notify_webhook", scheduledTime)
+	startTime := time.Now()
+	defer func() {
+		shared.RecordActivityEnd(metricsHandler, startTime, err)
+		logger.Info("NotifyWebhook activity completed")
+	}()
+
+	if err = checkURL(url); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		err = temporal.NewNonRetryableApplicationError(err.Error(), webhookURLType, err)
+		return err
+	}
+	info := activity.GetInfo(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(IDHeader, info.WorkflowExecution.ID+"/"+info.ActivityID)
+	if len(a.Secret) > 0 {
+		timestamp := time.Now().Unix()
+		req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		req.Header.Set(SignatureHeader, Sign(a.Secret, timestamp, payload))
+	}
+
+	logger.Info("Sending webhook", "event", event, "attempt", info.Attempt)
+	resp, err := a.HTTPClient.Do(req)
+	if err != nil {
+		logger.Error("Failed to send webhook", "error", err)
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if err = classify(resp); err != nil {
+		logger.Error("Webhook did not accept the notification", "status", resp.StatusCode, "error", err)
+	}
+	return err
+}
+
+// ValidateURL rejects URLs that are not absolute http or https URLs, the only
+// ones NotifyWebhook delivers to. Callers taking webhook URLs as input check
+// them with it before starting a workflow.
+func ValidateURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook URL %q is not an absolute http or https URL", s)
+	}
+	return nil
+}
+
+// checkURL is ValidateURL failing the delivery for good
+func checkURL(s string) error {
+	if err := ValidateURL(s); err != nil {
+		return temporal.NewNonRetryableApplicationError(err.Error(), webhookURLType, nil)
+	}
+	return nil
+}
+
+// classify turns a response into the error deciding whether the delivery is
+// retried
+func classify(resp *http.Response) error {
+	switch code := resp.StatusCode; {
+	case code >= 200 && code <= 299:
+		return nil
+	case code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable:
+		msg := fmt.Sprintf("webhook answered %s", resp.Status)
+		if delay, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return temporal.NewApplicationErrorWithOptions(msg, "", temporal.ApplicationErrorOptions{NextRetryDelay: delay})
+		}
+		return errors.New(msg)
+	case code == http.StatusRequestTimeout || code >= 500:
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	default:
+		return temporal.NewNonRetryableApplicationError(fmt.Sprintf("webhook answered %s", resp.Status), webhookRejectedType, nil)
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
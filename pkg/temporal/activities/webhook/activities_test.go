@@ -0,0 +1,159 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+var testSecret = []byte("test-secret")
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver answers every notification with status and headers and records
+// what it received
+func receiver(t *testing.T, status int, headers map[string]string) (*httptest.Server, *received) {
+	t.Helper()
+
+	var got received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.header = r.Header.Clone()
+		got.body, _ = io.ReadAll(r.Body)
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &got
+}
+
+func notify(t *testing.T, activities *WebhookActivities, url string, payload json.RawMessage) error {
+	t.Helper()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	env.RegisterActivity(activities)
+	_, err := env.ExecuteActivity(activities.NotifyWebhook, url, "ip.drift", payload, time.Now().UnixNano())
+	return err
+}
+
+func TestNotifyWebhookSigned(t *testing.T) {
+	server, got := receiver(t, http.StatusNoContent, nil)
+
+	payload := json.RawMessage(`{"ip":"203.0.113.7"}`)
+	err := notify(t, &WebhookActivities{HTTPClient: server.Client(), Secret: testSecret}, server.URL, payload)
+	if err != nil {
+		t.Fatalf("NotifyWebhook: %v", err)
+	}
+
+	if string(got.body) != string(payload) {
+		t.Errorf("body = %s, want %s", got.body, payload)
+	}
+	if got.header.Get(EventHeader) != "ip.drift" || got.header.Get("Content-Type") != "application/json" || got.header.Get(IDHeader) == "" {
+		t.Errorf("headers = %v", got.header)
+	}
+	err = Verify(testSecret, got.header.Get(TimestampHeader), got.header.Get(SignatureHeader), got.body, time.Minute, time.Now())
+	if err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestNotifyWebhookUnsigned(t *testing.T) {
+	server, got := receiver(t, http.StatusOK, nil)
+
+	if err := notify(t, &WebhookActivities{HTTPClient: server.Client()}, server.URL, json.RawMessage(`{}`)); err != nil {
+		t.Fatalf("NotifyWebhook: %v", err)
+	}
+	if got.header.Get(SignatureHeader) != "" || got.header.Get(TimestampHeader) != "" {
+		t.Errorf("headers = %v, want no signature without a secret", got.header)
+	}
+}
+
+func TestNotifyWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		headers       map[string]string
+		wantRetryable bool
+		wantDelay     time.Duration
+	}{
+		{name: "server error", status: http.StatusBadGateway, wantRetryable: true},
+		{name: "request timeout", status: http.StatusRequestTimeout, wantRetryable: true},
+		{name: "rate limited", status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "7"}, wantRetryable: true, wantDelay: 7 * time.Second},
+		{name: "bad request", status: http.StatusBadRequest},
+		{name: "gone", status: http.StatusGone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := receiver(t, tt.status, tt.headers)
+
+			err := notify(t, &WebhookActivities{HTTPClient: server.Client(), Secret: testSecret}, server.URL, json.RawMessage(`{}`))
+			if err == nil {
+				t.Fatal("NotifyWebhook succeeded, want error")
+			}
+			var appErr *temporal.ApplicationError
+			if !errors.As(err, &appErr) {
+				t.Fatalf("err = %v, want an application error", err)
+			}
+			if appErr.NonRetryable() == tt.wantRetryable {
+				t.Errorf("non-retryable = %v, want %v", appErr.NonRetryable(), !tt.wantRetryable)
+			}
+			if appErr.NextRetryDelay() != tt.wantDelay {
+				t.Errorf("next retry delay = %v, want %v", appErr.NextRetryDelay(), tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestNotifyWebhookInvalidURL(t *testing.T) {
+	err := notify(t, &WebhookActivities{HTTPClient: http.DefaultClient}, "file:///etc/passwd", json.RawMessage(`{}`))
+
+	var appErr *temporal.ApplicationError
+	if !errors.As(err, &appErr) || !appErr.NonRetryable() {
+		t.Fatalf("err = %v, want a non-retryable error", err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	body := []byte(`{"ip":"203.0.113.7"}`)
+	signature := Sign(testSecret, now.Unix(), body)
+	timestamp := "1800000000"
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		timestamp string
+		body      []byte
+		now       time.Time
+		wantErr   bool
+	}{
+		{name: "valid", secret: testSecret, timestamp: timestamp, body: body, now: now.Add(30 * time.Second)},
+		{name: "tampered body", secret: testSecret, timestamp: timestamp, body: []byte(`{"ip":"198.51.100.20"}`), now: now, wantErr: true},
+		{name: "other secret", secret: []byte("other"), timestamp: timestamp, body: body, now: now, wantErr: true},
+		{name: "replayed", secret: testSecret, timestamp: timestamp, body: body, now: now.Add(10 * time.Minute), wantErr: true},
+		{name: "shifted timestamp", secret: testSecret, timestamp: "1800000060", body: body, now: now, wantErr: true},
+		{name: "invalid timestamp", secret: testSecret, timestamp: "yesterday", body: body, now: now, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.secret, tt.timestamp, signature, tt.body, 5*time.Minute, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package shared
 
 import (
+	"strconv"
 	"time"
 
 	"go.temporal.io/sdk/client"
@@ -13,6 +14,12 @@ const (
 	activityStartedCount = "activity_started"
 	activityFailedCount  = "activity_failed"
 	activitySuccessCount = "activity_succeeded"
+
+	workflowCancelledCount = "workflow_cancelled"
+	ipDriftCount           = "ip_drift_detected"
+
+	payloadBytesBefore = "payload_compression_bytes_before"
+	payloadBytesAfter  = "payload_compression_bytes_after"
 )
 
 func RecordActivityStart(handler client.MetricsHandler, activityType string, timeStart int64) client.MetricsHandler {
@@ -34,3 +41,31 @@ func RecordActivityEnd(handler client.MetricsHandler, startTime time.Time, err e
 	}
 	handler.Counter(activitySuccessCount).Inc(1)
 }
+
+// RecordWorkflowCancelled counts a workflow cancelled while in stage
+func RecordWorkflowCancelled(handler client.MetricsHandler, stage string) {
+	handler.WithTags(map[string]string{
+		"stage": stage,
+	}).Counter(workflowCancelledCount).Inc(1)
+}
+
+// RecordIPDrift counts a change of each of the fields of the egress IP's
+// details
+func RecordIPDrift(handler client.MetricsHandler, fields []string) {
+	for _, field := range fields {
+		handler.WithTags(map[string]string{
+			"field": field,
+		}).Counter(ipDriftCount).Inc(1)
+	}
+}
+
+// RecordPayloadCompression counts the bytes of a payload before and after the
+// compression codec, tagged with whether it was compressed
+func RecordPayloadCompression(handler client.MetricsHandler, algorithm string, compressed bool, before, after int) {
+	handler = handler.WithTags(map[string]string{
+		"algorithm":  algorithm,
+		"compressed": strconv.FormatBool(compressed),
+	})
+	handler.Counter(payloadBytesBefore).Inc(int64(before))
+	handler.Counter(payloadBytesAfter).Inc(int64(after))
+}
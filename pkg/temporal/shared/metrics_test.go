@@ -0,0 +1,43 @@
+package shared
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/metrics"
+)
+
+func TestRecordActivityMetrics(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantSucceeded int64
+		wantFailed    int64
+	}{
+		{name: "success", wantSucceeded: 1},
+		{name: "failure", err: errors.New("boom"), wantFailed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := metrics.NewMemoryReporter()
+			handler := reporter.MetricsHandler().WithTags(map[string]string{"stage": "GetIP"})
+
+			scheduled := time.Now().Add(-time.Second).UnixNano()
+			handler = RecordActivityStart(handler, "activity.get_ip", scheduled)
+			RecordActivityEnd(handler, time.Now(), tt.err)
+
+			tags := map[string]string{"stage": "GetIP", "operation": "activity.get_ip"}
+			reporter.AssertCounter(t, activityStartedCount, tags, 1)
+			reporter.AssertCounter(t, activitySuccessCount, tags, tt.wantSucceeded)
+			reporter.AssertCounter(t, activityFailedCount, tags, tt.wantFailed)
+			reporter.AssertTimerCount(t, activityLatency, tags, 1)
+
+			latencies := reporter.Timers(scheduleToStartLatency, tags)
+			if len(latencies) != 1 || latencies[0] < time.Second {
+				t.Errorf("schedule_to_start_latency = %v, want one value of at least 1s", latencies)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/workflow"
+)
+
+// Saga collects the compensations of the steps a workflow has completed, so
+// a failure or cancellation part way through can undo their side effects.
+// Register a compensation right after its step succeeds; Compensate runs them
+// last to first. The zero value is ready to use.
+type Saga struct {
+	compensations []compensation
+}
+
+type compensation struct {
+	activity any
+	args     []any
+}
+
+// AddCompensation registers activity, called with args, to undo the step that
+// just succeeded. Compensations may run more than once and must be
+// idempotent.
+func (s *Saga) AddCompensation(activity any, args ...any) {
+	s.compensations = append(s.compensations, compensation{activity: activity, args: args})
+}
+
+// Compensate runs the registered compensations in reverse order with the
+// activity options of ctx. It runs on a disconnected context, so it also
+// works once ctx was cancelled. A failed compensation does not stop the ones
+// before it; their errors are joined.
+func (s *Saga) Compensate(ctx workflow.Context) error {
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	logger := workflow.GetLogger(ctx)
+
+	var errs []error
+	for i := len(s.compensations) - 1; i >= 0; i-- {
+		c := s.compensations[i]
+		if err := workflow.ExecuteActivity(ctx, c.activity, c.args...).Get(ctx, nil); err != nil {
+			logger.Error("Compensation failed", "step", i, "error", err)
+			errs = append(errs, fmt.Errorf("compensation %d: %w", i, err))
+		}
+	}
+	s.compensations = nil
+	return errors.Join(errs...)
+}
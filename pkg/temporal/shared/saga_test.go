@@ -0,0 +1,72 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+// sagaWorkflow registers an undo per step, then fails
+func sagaWorkflow(ctx workflow.Context, steps []string) (err error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{MaximumAttempts: 1},
+	})
+	var saga Saga
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, saga.Compensate(ctx))
+		}
+	}()
+
+	for _, step := range steps {
+		saga.AddCompensation("Undo", step)
+	}
+	return errors.New("step failed")
+}
+
+func TestSagaCompensate(t *testing.T) {
+	tests := []struct {
+		name      string
+		failUndo  string
+		wantError string
+	}{
+		{name: "compensated", wantError: "step failed"},
+		{name: "compensation fails", failUndo: "save", wantError: "compensation 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var suite testsuite.WorkflowTestSuite
+			env := suite.NewTestWorkflowEnvironment()
+
+			var undone []string
+			env.RegisterActivityWithOptions(func(_ context.Context, step string) error {
+				undone = append(undone, step)
+				if step == tt.failUndo {
+					return errors.New("undo failed")
+				}
+				return nil
+			}, activity.RegisterOptions{Name: "Undo"})
+
+			env.ExecuteWorkflow(sagaWorkflow, []string{"save", "notify", "publish"})
+
+			err := env.GetWorkflowError()
+			if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantError)
+			}
+			// Every compensation runs, last step first, whatever fails
+			if want := []string{"publish", "notify", "save"}; !slices.Equal(undone, want) {
+				t.Errorf("undone = %v, want %v", undone, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package shared
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+)
+
+// Custom search attributes. They must be registered on the namespace before
+// workflows use them, e.g. with
+// `temporal operator search-attribute create --name SubmitterName --type Keyword`.
+//
+// Search attributes bypass the payload codecs and are stored in plaintext,
+// so SubmitterName, ResolvedIP, CountryCode and ISP, which describe a person,
+// are left unset while payloads are encrypted.
+var (
+	// SubmitterNameKey holds the name a lookup was submitted for
+	SubmitterNameKey = temporal.NewSearchAttributeKeyKeyword("SubmitterName")
+	// ResolvedIPKey holds the IP address the lookup resolved
+	ResolvedIPKey = temporal.NewSearchAttributeKeyKeyword("ResolvedIP")
+	// CountryCodeKey holds the ISO 3166 country code of the resolved IP
+	CountryCodeKey = temporal.NewSearchAttributeKeyKeyword("CountryCode")
+	// ISPKey holds the internet service provider of the resolved IP
+	ISPKey = temporal.NewSearchAttributeKeyKeyword("ISP")
+	// ProviderUsedKey holds the geolocation provider queried for the lookup
+	ProviderUsedKey = temporal.NewSearchAttributeKeyKeyword("ProviderUsed")
+)
+
+// RequestMemoKey is the memo field holding the RequestMetadata of a workflow
+// started by the web server.
+const RequestMemoKey = "request"
+
+// RequestMetadata describes the HTTP request that started a workflow. Unlike
+// search attributes, memo fields are not indexed; they are returned with the
+// execution by describe and list calls.
+type RequestMetadata struct {
+	Source      string    `json:"source"` // "form" or "api"
+	RemoteAddr  string    `json:"remoteAddr,omitempty"`
+	UserAgent   string    `json:"userAgent,omitempty"`
+	SubmittedAt time.Time `json:"submittedAt"`
+}
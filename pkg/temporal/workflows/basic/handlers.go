@@ -0,0 +1,172 @@
+package basic
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	"go.temporal.io/sdk/temporal"
+)
+
+const (
+	// StatusQuery returns the Status of a GetAddressFromIP execution.
+	StatusQuery = "status"
+
+	// SkipEnrichmentSignal makes GetAddressFromIP skip the location and ISP
+	// lookups it has not finished yet and return with what it has. The signal
+	// takes no arguments.
+	SkipEnrichmentSignal = "skip-enrichment"
+
+	// ChangeTargetUpdate replaces the IP being enriched and/or adds enrichment
+	// fields. It takes a ChangeTargetRequest and returns a ChangeTargetResult
+	// once the new lookup has finished.
+	ChangeTargetUpdate = "change-target"
+)
+
+// Stages reported in Status.Stage.
+const (
+	StageStarted                    = "started"
+	StageGetIP                      = "GetIP"
+	StageGetLocationInfo            = "GetLocationInfo"
+	StageGetInternetServiceProvider = "GetInternetServiceProvider"
+	StageGetIPInfo                  = "GetIPInfo" // only counted in Attempts, run by ChangeTargetUpdate
+	StageNotifyWebhook              = "NotifyWebhook"
+	StageCompleted                  = "completed"
+)
+
+// Status is the answer to StatusQuery.
+type Status struct {
+	Stage string `json:"stage"`
+	// Attempts counts the activity executions scheduled per stage. Retries of
+	// a single execution are handled by the server and show up on the pending
+	// activity in DescribeWorkflowExecution instead.
+	Attempts map[string]int `json:"attempts"`
+
+	// Data gathered so far
+	IP          string `json:"ip,omitempty"`
+	Location    string `json:"location,omitempty"`
+	CountryCode string `json:"countryCode,omitempty"`
+	ISP         string `json:"isp,omitempty"`
+
+	// Details holds the extra enrichment fields requested through
+	// ChangeTargetUpdate, keyed by field name.
+	Details map[string]string `json:"details,omitempty"`
+
+	EnrichmentSkipped bool `json:"enrichmentSkipped"`
+}
+
+func (s *Status) enter(stage string) {
+	s.Stage = stage
+	s.Attempts[stage]++
+}
+
+// snapshot copies s so a query never observes later updates.
+func (s *Status) snapshot() Status {
+	c := *s
+	c.Attempts = make(map[string]int, len(s.Attempts))
+	for stage, n := range s.Attempts {
+		c.Attempts[stage] = n
+	}
+	if s.Details != nil {
+		c.Details = make(map[string]string, len(s.Details))
+		for field, value := range s.Details {
+			c.Details[field] = value
+		}
+	}
+	return c
+}
+
+// searchAttributes indexes the data gathered so far. Fields cleared by a
+// ChangeTargetUpdate are unset; the provider is only ever set, as the server
+// already sets it when starting the workflow. With omitSensitive only the
+// provider is indexed.
+func (s *Status) searchAttributes(omitSensitive bool) []temporal.SearchAttributeUpdate {
+	var updates []temporal.SearchAttributeUpdate
+	if !omitSensitive {
+		updates = append(updates,
+			keywordUpdate(shared.ResolvedIPKey, s.IP),
+			keywordUpdate(shared.CountryCodeKey, s.CountryCode),
+			keywordUpdate(shared.ISPKey, s.ISP),
+		)
+	}
+	if s.Location != "" || s.ISP != "" {
+		updates = append(updates, shared.ProviderUsedKey.ValueSet(ip.Provider))
+	}
+	return updates
+}
+
+func keywordUpdate(key temporal.SearchAttributeKeyKeyword, value string) temporal.SearchAttributeUpdate {
+	if value == "" {
+		return key.ValueUnset()
+	}
+	return key.ValueSet(value)
+}
+
+// CancelledResult is the detail of the CanceledError GetAddressFromIP returns
+// when it is cancelled. Decode it with CanceledError.Details.
+type CancelledResult struct {
+	// Status at the time of the cancellation; Stage is the interrupted stage
+	Status Status `json:"status"`
+	// Result describes the data gathered before the cancellation, if any
+	Result string `json:"result,omitempty"`
+}
+
+// ChangeTargetRequest is the argument of ChangeTargetUpdate.
+type ChangeTargetRequest struct {
+	// IP replaces the address being enriched; empty keeps the current one
+	IP string `json:"ip,omitempty"`
+	// Fields lists extra enrichment fields, see EnrichmentFields
+	Fields []string `json:"fields,omitempty"`
+}
+
+// ChangeTargetResult is the result of ChangeTargetUpdate.
+type ChangeTargetResult struct {
+	Result  string            `json:"result"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// enrichmentFields extracts the optional enrichment fields from an ip-api
+// response.
+var enrichmentFields = map[string]func(ip.IPInfo) string{
+	"countryCode": func(i ip.IPInfo) string { return i.CountryCode },
+	"timezone":    func(i ip.IPInfo) string { return i.Timezone },
+	"zip":         func(i ip.IPInfo) string { return i.Zip },
+	"org":         func(i ip.IPInfo) string { return i.Org },
+	"as":          func(i ip.IPInfo) string { return i.AS },
+	"coordinates": func(i ip.IPInfo) string {
+		return strconv.FormatFloat(i.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(i.Lon, 'f', -1, 64)
+	},
+}
+
+// EnrichmentFields returns the field names ChangeTargetRequest.Fields accepts.
+func EnrichmentFields() []string {
+	fields := make([]string, 0, len(enrichmentFields))
+	for field := range enrichmentFields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	return fields
+}
+
+// validate rejects requests before they are written to history.
+func (r ChangeTargetRequest) validate(status *Status) error {
+	if r.IP == "" && len(r.Fields) == 0 {
+		return errors.New("ip or fields is required")
+	}
+	if r.IP != "" && net.ParseIP(r.IP) == nil {
+		return fmt.Errorf("invalid IP address %q", r.IP)
+	}
+	if r.IP == "" && status.IP == "" {
+		return errors.New("the IP address has not been resolved yet")
+	}
+	for _, field := range r.Fields {
+		if _, ok := enrichmentFields[field]; !ok {
+			return fmt.Errorf("unknown enrichment field %q, want one of %v", field, EnrichmentFields())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package basic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/store"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/webhook"
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Events of the notifications PublishAddressFromIP sends
+const (
+	PublishedEvent = "getAddressFromIP.published"
+	RetractedEvent = "getAddressFromIP.retracted"
+)
+
+// PublishOptions are the input of PublishAddressFromIP besides the name.
+type PublishOptions struct {
+	// WebhookURL is told about the record, and about its retraction
+	WebhookURL string `json:"webhookUrl"`
+}
+
+// Record is what PublishAddressFromIP stores and announces, keyed by the
+// workflow ID.
+type Record struct {
+	WorkflowID  string `json:"workflowId"`
+	Name        string `json:"name"`
+	IP          string `json:"ip"`
+	Location    string `json:"location"`
+	CountryCode string `json:"countryCode"`
+	ISP         string `json:"isp"`
+	Published   bool   `json:"published"`
+}
+
+// Retraction is the body of a RetractedEvent notification.
+type Retraction struct {
+	WorkflowID string `json:"workflowId"`
+}
+
+// publishOptions give up after a few attempts, so a step that keeps failing
+// fails the workflow and its predecessors are compensated.
+var publishOptions = workflow.ActivityOptions{
+	StartToCloseTimeout: time.Minute,
+	RetryPolicy: &temporal.RetryPolicy{
+		InitialInterval:    time.Second,
+		MaximumInterval:    30 * time.Second,
+		BackoffCoefficient: 2,
+		MaximumAttempts:    5,
+	},
+}
+
+// PublishAddressFromIP looks up the IP address like GetAddressFromIP, then
+// stores the result as a record, announces it to a webhook and marks the
+// record published. The store and the webhook are external side effects:
+// when a step fails or the workflow is cancelled, the steps that completed
+// are undone last to first, retracting the announcement and deleting the
+// record.
+func PublishAddressFromIP(ctx workflow.Context, name string, opts PublishOptions) (record Record, err error) {
+	if opts.WebhookURL == "" {
+		return Record{}, temporal.NewNonRetryableApplicationError("a webhook URL is required", "InvalidPublishOptions", nil)
+	}
+	ctx = workflow.WithActivityOptions(ctx, publishOptions)
+	var ipActivities *ip.IPActivities
+	var storeActivities *store.StoreActivities
+	var webhookActivities *webhook.WebhookActivities
+	scheduledTimeNanos := workflow.Now(ctx).UnixNano()
+
+	var saga shared.Saga
+	defer func() {
+		if err == nil {
+			return
+		}
+		workflow.GetLogger(ctx).Info("Undoing published record", "error", err)
+		if compensateErr := saga.Compensate(ctx); compensateErr != nil {
+			err = errors.Join(err, compensateErr)
+		}
+	}()
+
+	record = Record{WorkflowID: workflow.GetInfo(ctx).WorkflowExecution.ID, Name: name}
+	if err := workflow.ExecuteActivity(ctx, ipActivities.GetIP, scheduledTimeNanos).Get(ctx, &record.IP); err != nil {
+		return Record{}, fmt.Errorf("failed to get IP: %w", err)
+	}
+	var info ip.IPInfo
+	if err := workflow.ExecuteActivity(ctx, ipActivities.GetIPInfo, record.IP, scheduledTimeNanos).Get(ctx, &info); err != nil {
+		return Record{}, fmt.Errorf("failed to get IP info: %w", err)
+	}
+	record.Location, record.CountryCode, record.ISP = info.Location(), info.CountryCode, info.ISP
+
+	save := func(record Record) error {
+		payload, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return workflow.ExecuteActivity(ctx, storeActivities.SaveRecord, record.WorkflowID, json.RawMessage(payload), scheduledTimeNanos).Get(ctx, nil)
+	}
+
+	if err := save(record); err != nil {
+		return Record{}, fmt.Errorf("failed to save record: %w", err)
+	}
+	saga.AddCompensation(storeActivities.DeleteRecord, record.WorkflowID, scheduledTimeNanos)
+
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return Record{}, err
+	}
+	err = workflow.ExecuteActivity(ctx, webhookActivities.NotifyWebhook, opts.WebhookURL, PublishedEvent, json.RawMessage(payload), scheduledTimeNanos).Get(ctx, nil)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to announce record: %w", err)
+	}
+	retraction, err := json.Marshal(Retraction{WorkflowID: record.WorkflowID})
+	if err != nil {
+		return Record{}, err
+	}
+	saga.AddCompensation(webhookActivities.NotifyWebhook, opts.WebhookURL, RetractedEvent, json.RawMessage(retraction), scheduledTimeNanos)
+
+	record.Published = true
+	if err := save(record); err != nil {
+		return Record{}, fmt.Errorf("failed to mark record published: %w", err)
+	}
+	return record, nil
+}
@@ -0,0 +1,170 @@
+package basic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/store"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/webhook"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+)
+
+const testWebhookURL = "https://hooks.example.com/records"
+
+type publishEnv struct {
+	*testsuite.TestWorkflowEnvironment
+	store   *store.StoreActivities
+	webhook *webhook.WebhookActivities
+	// steps lists the side effects in the order they completed, as
+	// "SaveRecord", "DeleteRecord" or the event of a notification
+	steps []string
+}
+
+// newPublishEnv mocks the lookups and records the side effects
+func newPublishEnv() *publishEnv {
+	env, activities := newTestEnv()
+	e := &publishEnv{
+		TestWorkflowEnvironment: env,
+		store:                   &store.StoreActivities{},
+		webhook:                 &webhook.WebhookActivities{},
+	}
+	env.RegisterActivity(e.store)
+	env.RegisterActivity(e.webhook)
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil)
+
+	env.SetOnActivityCompletedListener(func(info *activity.Info, _ converter.EncodedValue, err error) {
+		// Notifications record their event themselves
+		if name := info.ActivityType.Name; err == nil && (name == "SaveRecord" || name == "DeleteRecord") {
+			e.steps = append(e.steps, name)
+		}
+	})
+	return e
+}
+
+// notifyWebhook answers notifications, recording their events
+func (e *publishEnv) notifyWebhook(fail string) func(context.Context, string, string, json.RawMessage, int64) error {
+	return func(_ context.Context, _, event string, _ json.RawMessage, _ int64) error {
+		if event == fail {
+			return temporal.NewNonRetryableApplicationError("webhook answered 410 Gone", "WebhookRejected", nil)
+		}
+		e.steps = append(e.steps, event)
+		return nil
+	}
+}
+
+func TestPublishAddressFromIP(t *testing.T) {
+	env := newPublishEnv()
+	var saved []Record
+	env.OnActivity(env.store.SaveRecord, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, _ string, payload json.RawMessage, _ int64) error {
+			var r Record
+			json.Unmarshal(payload, &r)
+			saved = append(saved, r)
+			return nil
+		})
+	env.OnActivity(env.webhook.NotifyWebhook, mock.Anything, testWebhookURL, mock.Anything, mock.Anything, mock.Anything).Return(env.notifyWebhook(""))
+
+	env.ExecuteWorkflow(PublishAddressFromIP, "Temporal", PublishOptions{WebhookURL: testWebhookURL})
+
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	var record Record
+	if err := env.GetWorkflowResult(&record); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	if !record.Published || record.IP != testIP || record.Location != testLocation || record.ISP != testISP {
+		t.Errorf("record = %+v", record)
+	}
+	if len(saved) != 2 || saved[0].Published || !saved[1].Published {
+		t.Errorf("saved = %+v, want a draft, then the published record", saved)
+	}
+	if want := []string{"SaveRecord", PublishedEvent, "SaveRecord"}; !slices.Equal(env.steps, want) {
+		t.Errorf("steps = %v, want %v", env.steps, want)
+	}
+}
+
+func TestPublishAddressFromIPCompensation(t *testing.T) {
+	storeDown := temporal.NewNonRetryableApplicationError("disk full", "StoreUnavailable", nil)
+
+	tests := []struct {
+		name          string
+		saveErrors    []error
+		failEvent     string
+		wantStepsDone []string
+	}{
+		{
+			name:          "announcement fails",
+			failEvent:     PublishedEvent,
+			wantStepsDone: []string{"SaveRecord", "DeleteRecord"},
+		},
+		{
+			name:          "marking published fails",
+			saveErrors:    []error{nil, storeDown},
+			wantStepsDone: []string{"SaveRecord", PublishedEvent, RetractedEvent, "DeleteRecord"},
+		},
+		{
+			name:          "first save fails",
+			saveErrors:    []error{storeDown},
+			wantStepsDone: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newPublishEnv()
+			saves := 0
+			env.OnActivity(env.store.SaveRecord, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+				func(context.Context, string, json.RawMessage, int64) error {
+					saves++
+					if saves <= len(tt.saveErrors) {
+						return tt.saveErrors[saves-1]
+					}
+					return nil
+				})
+			env.OnActivity(env.store.DeleteRecord, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			env.OnActivity(env.webhook.NotifyWebhook, mock.Anything, testWebhookURL, mock.Anything, mock.Anything, mock.Anything).Return(env.notifyWebhook(tt.failEvent))
+
+			env.ExecuteWorkflow(PublishAddressFromIP, "Temporal", PublishOptions{WebhookURL: testWebhookURL})
+
+			if err := env.GetWorkflowError(); err == nil {
+				t.Fatal("workflow succeeded, want the step's failure")
+			}
+			// Completed steps are undone last to first
+			if !slices.Equal(env.steps, tt.wantStepsDone) {
+				t.Errorf("steps = %v, want %v", env.steps, tt.wantStepsDone)
+			}
+		})
+	}
+}
+
+func TestPublishAddressFromIPCancelled(t *testing.T) {
+	env := newPublishEnv()
+	env.OnActivity(env.store.SaveRecord, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(env.store.DeleteRecord, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(env.webhook.NotifyWebhook, mock.Anything, testWebhookURL, PublishedEvent, mock.Anything, mock.Anything).
+		After(time.Minute).Return(env.notifyWebhook(""))
+
+	env.RegisterDelayedCallback(env.CancelWorkflow, 30*time.Second)
+
+	env.ExecuteWorkflow(PublishAddressFromIP, "Temporal", PublishOptions{WebhookURL: testWebhookURL})
+
+	var canceledErr *temporal.CanceledError
+	if err := env.GetWorkflowError(); !errors.As(err, &canceledErr) {
+		t.Fatalf("err = %v, want a canceled error", err)
+	}
+	// The announcement was cancelled before it completed, so only the
+	// record is undone
+	if want := []string{"SaveRecord", "DeleteRecord"}; !slices.Equal(env.steps, want) {
+		t.Errorf("steps = %v, want %v", env.steps, want)
+	}
+}
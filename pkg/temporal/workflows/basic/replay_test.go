@@ -0,0 +1,75 @@
+package basic
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	sdklog "go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// Histories in testdata/histories are recorded with cmd/capture-history.
+// Replaying them against the current code fails when a change would break
+// executions that were started by an earlier version of the workflow.
+func historyFiles(t *testing.T) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "histories", "*.json"))
+	if err != nil {
+		t.Fatalf("glob histories: %v", err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no histories in testdata/histories")
+	}
+	return files
+}
+
+func TestReplayHistories(t *testing.T) {
+	for _, file := range historyFiles(t) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflow(GetAddressFromIP)
+
+			if err := replayer.ReplayWorkflowHistoryFromJSONFile(nil, file); err != nil {
+				t.Fatalf("replay %s: %v", file, err)
+			}
+		})
+	}
+}
+
+// getAddressFromIPWithoutSleep is GetAddressFromIP minus its initial timer, a
+// change that is not safe for executions already in flight.
+func getAddressFromIPWithoutSleep(ctx workflow.Context, name string) (string, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{BackoffCoefficient: 2},
+	})
+
+	var ipActivities *ip.IPActivities
+	var addr string
+	if err := workflow.ExecuteActivity(ctx, ipActivities.GetIP, workflow.Now(ctx).UnixNano()).Get(ctx, &addr); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Hello, %s. Your IP is %s", name, addr), nil
+}
+
+func TestReplayDetectsNonDeterminism(t *testing.T) {
+	for _, file := range historyFiles(t) {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			replayer := worker.NewWorkflowReplayer()
+			replayer.RegisterWorkflowWithOptions(getAddressFromIPWithoutSleep, workflow.RegisterOptions{Name: "GetAddressFromIP"})
+
+			// The expected failure is logged with a stack trace; keep it quiet.
+			quiet := sdklog.NewStructuredLogger(slog.New(slog.DiscardHandler))
+			if err := replayer.ReplayWorkflowHistoryFromJSONFile(quiet, file); err == nil {
+				t.Fatal("replay succeeded, want a non-determinism error")
+			}
+		})
+	}
+}
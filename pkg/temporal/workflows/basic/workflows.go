@@ -1,16 +1,67 @@
 package basic
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/webhook"
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
+// Change IDs passed to workflow.GetVersion. Any edit to the commands the
+// workflow issues (activities, timers, their order) is guarded by a new change
+// ID so executions started by older code still replay. Record a new replay
+// history with `make capture-history` after adding one.
+const (
+	// removeInitialSleepChangeID drops the 500ms timer before GetIP.
+	removeInitialSleepChangeID = "remove-initial-sleep"
+	// searchAttributesChangeID upserts search attributes as results arrive
+	// and looks up the location with GetIPInfo, which also returns the
+	// country code.
+	searchAttributesChangeID = "search-attributes"
+)
+
+// CompletedEvent is the event of the notification sent to Options.CallbackURL.
+const CompletedEvent = "getAddressFromIP.completed"
+
+// Options are the optional inputs of GetAddressFromIP. Executions started
+// before they existed carry no payload for them and decode the zero value.
+type Options struct {
+	// CallbackURL receives a Notification once the workflow has its result
+	CallbackURL string `json:"callbackUrl,omitempty"`
+	// OmitSensitiveAttributes leaves out the search attributes that carry
+	// personal data, which would otherwise be indexed in plaintext. The
+	// server sets it whenever it encrypts payloads.
+	OmitSensitiveAttributes bool `json:"omitSensitiveAttributes,omitempty"`
+}
+
+// Notification is the body POSTed to Options.CallbackURL.
+type Notification struct {
+	WorkflowID string `json:"workflowId"`
+	RunID      string `json:"runId"`
+	Name       string `json:"name"`
+	Result     string `json:"result"`
+	Status     Status `json:"status"`
+}
+
+// notifyOptions retry a callback for about ten minutes before giving up
+var notifyOptions = workflow.ActivityOptions{
+	StartToCloseTimeout: 30 * time.Second,
+	RetryPolicy: &temporal.RetryPolicy{
+		InitialInterval:    time.Second,
+		MaximumInterval:    time.Minute,
+		BackoffCoefficient: 2,
+		MaximumAttempts:    15,
+	},
+}
+
 // GetAddressFromIP is the Temporal Workflow that retrieves the IP address and location info.
-func GetAddressFromIP(ctx workflow.Context, name string) (string, error) {
+func GetAddressFromIP(ctx workflow.Context, name string, opts Options) (result string, err error) {
 	// Define the activity options, including the retry policy
 	ao := workflow.ActivityOptions{
 		StartToCloseTimeout: time.Minute,
@@ -23,22 +74,217 @@ func GetAddressFromIP(ctx workflow.Context, name string) (string, error) {
 	}
 	ctx = workflow.WithActivityOptions(ctx, ao)
 
+	status := &Status{Stage: StageStarted, Attempts: map[string]int{}}
+	defer func() {
+		if errors.Is(ctx.Err(), workflow.ErrCanceled) {
+			result, err = "", cancelled(ctx, name, status)
+		}
+	}()
+	if err := workflow.SetQueryHandler(ctx, StatusQuery, func() (Status, error) {
+		return status.snapshot(), nil
+	}); err != nil {
+		return "", err
+	}
+
+	// Lookups run on enrichCtx so a skip signal can cancel the one in flight
+	enrichCtx, cancelEnrichment := workflow.WithCancel(ctx)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		workflow.GetSignalChannel(ctx, SkipEnrichmentSignal).Receive(ctx, nil)
+		workflow.GetLogger(ctx).Info("Skipping remaining enrichment")
+		status.EnrichmentSkipped = true
+		cancelEnrichment()
+	})
+
+	// Upserts are commands of their own, so executions started before they
+	// were added replay without them.
+	indexed := workflow.GetVersion(ctx, searchAttributesChangeID, workflow.DefaultVersion, 1) >= 1
+	index := func(ctx workflow.Context) error {
+		updates := status.searchAttributes(opts.OmitSensitiveAttributes)
+		if !indexed || len(updates) == 0 {
+			return nil
+		}
+		return workflow.UpsertTypedSearchAttributes(ctx, updates...)
+	}
+
 	var ipActivities *ip.IPActivities
 
-	var ip string
 	scheduledTimeNanos := workflow.Now(ctx).UnixNano()
-	_ = workflow.Sleep(ctx, 500*time.Millisecond)
-	err := workflow.ExecuteActivity(ctx, ipActivities.GetIP, scheduledTimeNanos).Get(ctx, &ip)
+
+	if err := workflow.SetUpdateHandlerWithOptions(ctx, ChangeTargetUpdate,
+		func(ctx workflow.Context, req ChangeTargetRequest) (ChangeTargetResult, error) {
+			ctx = workflow.WithActivityOptions(ctx, ao)
+			return changeTarget(ctx, name, status, req, scheduledTimeNanos, index)
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(ctx workflow.Context, req ChangeTargetRequest) error {
+				return req.validate(status)
+			},
+		},
+	); err != nil {
+		return "", err
+	}
+
+	if v := workflow.GetVersion(ctx, removeInitialSleepChangeID, workflow.DefaultVersion, 1); v == workflow.DefaultVersion {
+		_ = workflow.Sleep(ctx, 500*time.Millisecond)
+	}
+	var detectedIP string
+	status.enter(StageGetIP)
+	err = workflow.ExecuteActivity(ctx, ipActivities.GetIP, scheduledTimeNanos).Get(ctx, &detectedIP)
 	if err != nil {
 		return "", fmt.Errorf("failed to get IP: %s", err)
 	}
+	// An update may already have replaced the detected address
+	if status.IP == "" {
+		status.IP = detectedIP
+		if err := index(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	// Enrichment stops once a skip signal arrives or an update takes over
+	enriching := func() bool {
+		return !status.EnrichmentSkipped && status.IP == detectedIP
+	}
+
+	if enriching() {
+		status.enter(StageGetLocationInfo)
+		var location, countryCode string
+		if indexed {
+			var info ip.IPInfo
+			err = workflow.ExecuteActivity(enrichCtx, ipActivities.GetIPInfo, detectedIP, scheduledTimeNanos).Get(enrichCtx, &info)
+			location, countryCode = info.Location(), info.CountryCode
+		} else {
+			err = workflow.ExecuteActivity(enrichCtx, ipActivities.GetLocationInfo, detectedIP, scheduledTimeNanos).Get(enrichCtx, &location)
+		}
+		if enriching() {
+			if err != nil {
+				return "", fmt.Errorf("failed to get location: %s", err)
+			}
+			status.Location, status.CountryCode = location, countryCode
+			if err := index(ctx); err != nil {
+				return "", err
+			}
+		}
+	}
+	if enriching() {
+		status.enter(StageGetInternetServiceProvider)
+		var isp string
+		err = workflow.ExecuteActivity(enrichCtx, ipActivities.GetInternetServiceProvider, detectedIP, scheduledTimeNanos).Get(enrichCtx, &isp)
+		if enriching() {
+			if err != nil {
+				return "", fmt.Errorf("failed to get ISP: %s", err)
+			}
+			status.ISP = isp
+			if err := index(ctx); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	// Let running updates finish so their callers get a result
+	if err := workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) }); err != nil {
+		return "", err
+	}
 
-	var location string
-	err = workflow.ExecuteActivity(ctx, ipActivities.GetLocationInfo, ip, scheduledTimeNanos).Get(ctx, &location)
+	result = greeting(name, status.IP, status.ISP, status.Location)
+	// Only executions started with a callback URL schedule the activity, so
+	// older executions replay unchanged
+	if opts.CallbackURL != "" {
+		status.enter(StageNotifyWebhook)
+		notify(ctx, opts.CallbackURL, name, result, status, scheduledTimeNanos)
+	}
+
+	status.Stage = StageCompleted
+	return result, nil
+}
+
+// notify posts the result to callbackURL. A delivery that still fails after
+// its retries is logged and leaves the result standing; the failed activity
+// stays visible in the history.
+func notify(ctx workflow.Context, callbackURL, name, result string, status *Status, scheduledTimeNanos int64) {
+	logger := workflow.GetLogger(ctx)
+	info := workflow.GetInfo(ctx)
+	payload, err := json.Marshal(Notification{
+		WorkflowID: info.WorkflowExecution.ID,
+		RunID:      info.WorkflowExecution.RunID,
+		Name:       name,
+		Result:     result,
+		Status:     status.snapshot(),
+	})
+	if err != nil {
+		logger.Error("Failed to encode notification", "error", err)
+		return
+	}
+
+	var webhookActivities *webhook.WebhookActivities
+	ctx = workflow.WithActivityOptions(ctx, notifyOptions)
+	err = workflow.ExecuteActivity(ctx, webhookActivities.NotifyWebhook, callbackURL, CompletedEvent, json.RawMessage(payload), scheduledTimeNanos).Get(ctx, nil)
+	if err != nil {
+		logger.Warn("Failed to notify callback URL", "error", err)
+	}
+}
+
+// cancelled cleans up after the execution was cancelled and returns the error
+// carrying a CancelledResult. The cleanup runs on a disconnected context, which
+// the cancellation does not reach.
+func cancelled(ctx workflow.Context, name string, status *Status) error {
+	cleanupCtx, _ := workflow.NewDisconnectedContext(ctx)
+	workflow.GetLogger(cleanupCtx).Info("Workflow cancelled", "stage", status.Stage)
+	shared.RecordWorkflowCancelled(workflow.GetMetricsHandler(cleanupCtx), status.Stage)
+
+	details := CancelledResult{Status: status.snapshot()}
+	if status.IP != "" {
+		details.Result = greeting(name, status.IP, status.ISP, status.Location)
+	}
+	return temporal.NewCanceledError(details)
+}
+
+// changeTarget looks up the IP of req, or the current one, records the
+// requested enrichment fields and indexes the result.
+func changeTarget(ctx workflow.Context, name string, status *Status, req ChangeTargetRequest, scheduledTimeNanos int64,
+	index func(workflow.Context) error) (ChangeTargetResult, error) {
+	if req.IP != "" && req.IP != status.IP {
+		status.IP = req.IP
+		status.Location, status.CountryCode, status.ISP, status.Details = "", "", "", nil
+	}
+	target := status.IP
+
+	var ipActivities *ip.IPActivities
+	var info ip.IPInfo
+	status.Attempts[StageGetIPInfo]++
+	err := workflow.ExecuteActivity(ctx, ipActivities.GetIPInfo, target, scheduledTimeNanos).Get(ctx, &info)
 	if err != nil {
-		return "", fmt.Errorf("failed to get location: %s", err)
+		return ChangeTargetResult{}, fmt.Errorf("failed to get IP info: %w", err)
+	}
+	if status.IP != target {
+		return ChangeTargetResult{}, fmt.Errorf("IP was changed to %s during the lookup", status.IP)
+	}
+
+	status.Location, status.CountryCode, status.ISP = info.Location(), info.CountryCode, info.ISP
+	if len(req.Fields) > 0 && status.Details == nil {
+		status.Details = make(map[string]string, len(req.Fields))
+	}
+	for _, field := range req.Fields {
+		status.Details[field] = enrichmentFields[field](info)
+	}
+	if err := index(ctx); err != nil {
+		return ChangeTargetResult{}, err
+	}
+
+	return ChangeTargetResult{
+		Result:  greeting(name, status.IP, status.ISP, status.Location),
+		Details: status.snapshot().Details,
+	}, nil
+}
+
+// greeting describes whatever the workflow found out about the caller.
+func greeting(name, ip, isp, location string) string {
+	s := fmt.Sprintf("Hello, %s. Your IP is %s", name, ip)
+	if isp != "" {
+		s += fmt.Sprintf(" (%s)", isp)
+	}
+	if location != "" {
+		s += " and your location is " + location
 	}
-	var isp string
-	err = workflow.ExecuteActivity(ctx, ipActivities.GetInternetServiceProvider, ip, scheduledTimeNanos).Get(ctx, &isp)
-	return fmt.Sprintf("Hello, %s. Your IP is %s (%s) and your location is %s", name, ip, isp, location), nil
+	return s
 }
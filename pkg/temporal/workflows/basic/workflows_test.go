@@ -0,0 +1,552 @@
+package basic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/internal/metrics"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/webhook"
+	"github.com/stretchr/testify/mock"
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	testIP       = "203.0.113.7"
+	testLocation = "Austin, Texas, United States"
+	testISP      = "Example Fiber"
+	wantGreeting = "Hello, Temporal. Your IP is 203.0.113.7 (Example Fiber) and your location is Austin, Texas, United States"
+)
+
+// testInfo is the ip-api answer for testIP
+var testInfo = ip.IPInfo{City: "Austin", RegionName: "Texas", Country: "United States", CountryCode: "US", ISP: testISP}
+
+// newTestEnv returns a workflow environment with IPActivities registered.
+// Tests mock every activity they expect the workflow to reach.
+func newTestEnv() (*testsuite.TestWorkflowEnvironment, *ip.IPActivities) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	activities := &ip.IPActivities{}
+	env.RegisterActivity(activities)
+	return env, activities
+}
+
+func workflowResult(t *testing.T, env *testsuite.TestWorkflowEnvironment) (string, error) {
+	t.Helper()
+
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	if err := env.GetWorkflowError(); err != nil {
+		return "", err
+	}
+	var result string
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	return result, nil
+}
+
+func TestGetAddressFromIP(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil).Once()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil).Once()
+	env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil).Once()
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+	got, err := workflowResult(t, env)
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if got != wantGreeting {
+		t.Errorf("result = %q, want %q", got, wantGreeting)
+	}
+	env.AssertExpectations(t)
+}
+
+func TestGetAddressFromIPCallback(t *testing.T) {
+	secret := []byte("test-secret")
+	var notification Notification
+	var event string
+	var verifyErr error
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = webhook.Verify(secret, r.Header.Get(webhook.TimestampHeader), r.Header.Get(webhook.SignatureHeader), body, time.Minute, time.Now())
+		event = r.Header.Get(webhook.EventHeader)
+		json.Unmarshal(body, &notification)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	env, activities := newTestEnv()
+	env.RegisterActivity(&webhook.WebhookActivities{HTTPClient: receiver.Client(), Secret: secret})
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil)
+	env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil)
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{CallbackURL: receiver.URL})
+
+	got, err := workflowResult(t, env)
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if got != wantGreeting {
+		t.Errorf("result = %q, want %q", got, wantGreeting)
+	}
+	if verifyErr != nil {
+		t.Fatalf("verify notification: %v", verifyErr)
+	}
+	if event != CompletedEvent {
+		t.Errorf("event = %q, want %q", event, CompletedEvent)
+	}
+	if notification.Result != wantGreeting || notification.Name != "Temporal" || notification.WorkflowID == "" || notification.Status.IP != testIP {
+		t.Errorf("notification = %+v", notification)
+	}
+}
+
+func TestGetAddressFromIPCallbackFails(t *testing.T) {
+	env, activities := newTestEnv()
+	webhookActivities := &webhook.WebhookActivities{}
+	env.RegisterActivity(webhookActivities)
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil)
+	env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil)
+	env.OnActivity(webhookActivities.NotifyWebhook, mock.Anything, "https://hooks.example.com/done", CompletedEvent, mock.Anything, mock.Anything).
+		Return(temporal.NewNonRetryableApplicationError("webhook answered 410 Gone", "WebhookRejected", nil)).Once()
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{CallbackURL: "https://hooks.example.com/done"})
+
+	// The result stands when the notification cannot be delivered
+	got, err := workflowResult(t, env)
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if got != wantGreeting {
+		t.Errorf("result = %q, want %q", got, wantGreeting)
+	}
+	env.AssertExpectations(t)
+}
+
+func TestGetAddressFromIPRetries(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return("", errors.New("connection reset")).Twice()
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil).Once()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil).Once()
+	env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil).Once()
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+	got, err := workflowResult(t, env)
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if got != wantGreeting {
+		t.Errorf("result = %q, want %q", got, wantGreeting)
+	}
+	env.AssertExpectations(t)
+}
+
+func TestGetAddressFromIPLookupFailures(t *testing.T) {
+	lookupErr := temporal.NewNonRetryableApplicationError("reserved range", "IPLookupFailed", nil)
+
+	tests := []struct {
+		name        string
+		locationErr error
+		ispErr      error
+		wantErr     string
+	}{
+		{name: "location", locationErr: lookupErr, wantErr: "failed to get location"},
+		{name: "isp", ispErr: lookupErr, wantErr: "failed to get ISP"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, activities := newTestEnv()
+			env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+			env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, tt.locationErr)
+			env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, tt.ispErr)
+
+			env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+			_, err := workflowResult(t, env)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want error containing %q", err, tt.wantErr)
+			}
+			if tt.locationErr != nil {
+				env.AssertActivityNotCalled(t, "GetInternetServiceProvider", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestGetAddressFromIPActivityTimeout(t *testing.T) {
+	env, activities := newTestEnv()
+	// The first attempt hits the StartToCloseTimeout and is retried.
+	startToClose := temporal.NewTimeoutError(enumspb.TIMEOUT_TYPE_START_TO_CLOSE, nil)
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return("", startToClose).Once()
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil).Once()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil).Once()
+	env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil).Once()
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+	got, err := workflowResult(t, env)
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if got != wantGreeting {
+		t.Errorf("result = %q, want %q", got, wantGreeting)
+	}
+	env.AssertExpectations(t)
+}
+
+func TestGetAddressFromIPExecutionTimeout(t *testing.T) {
+	env, activities := newTestEnv()
+	env.SetStartWorkflowOptions(client.StartWorkflowOptions{WorkflowExecutionTimeout: 10 * time.Minute})
+	// GetIP never succeeds. The retry policy does not cap attempts, so only
+	// the execution timeout stops the retries, surfacing the last failure.
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return("", errors.New("connection refused"))
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+	_, err := workflowResult(t, env)
+	if err == nil || !strings.Contains(err.Error(), "failed to get IP") {
+		t.Fatalf("err = %v, want error containing %q", err, "failed to get IP")
+	}
+}
+
+func TestGetAddressFromIPVersions(t *testing.T) {
+	tests := []struct {
+		name      string
+		version   workflow.Version
+		wantDelay time.Duration
+	}{
+		{name: "before remove-initial-sleep", version: workflow.DefaultVersion, wantDelay: 500 * time.Millisecond},
+		{name: "remove-initial-sleep", version: 1, wantDelay: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, activities := newTestEnv()
+			env.OnGetVersion(removeInitialSleepChangeID, workflow.DefaultVersion, 1).Return(tt.version)
+
+			start := env.Now()
+			var delay time.Duration
+			env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(
+				func(context.Context, int64) (string, error) {
+					delay = env.Now().Sub(start)
+					return testIP, nil
+				})
+			env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil)
+			env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil)
+
+			env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+			if _, err := workflowResult(t, env); err != nil {
+				t.Fatalf("workflow failed: %v", err)
+			}
+			if delay != tt.wantDelay {
+				t.Errorf("GetIP started after %v, want %v", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestGetAddressFromIPSearchAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		version workflow.Version
+		opts    Options
+		want    []map[string]string
+	}{
+		{
+			name:    "before search-attributes",
+			version: workflow.DefaultVersion,
+		},
+		{
+			name:    "search-attributes",
+			version: 1,
+			want: []map[string]string{
+				{"ResolvedIP": testIP},
+				{"ResolvedIP": testIP, "CountryCode": "US", "ProviderUsed": ip.Provider},
+				{"ResolvedIP": testIP, "CountryCode": "US", "ISP": testISP, "ProviderUsed": ip.Provider},
+			},
+		},
+		{
+			// Nothing is upserted until there is a provider to index
+			name:    "sensitive attributes omitted",
+			version: 1,
+			opts:    Options{OmitSensitiveAttributes: true},
+			want: []map[string]string{
+				{"ProviderUsed": ip.Provider},
+				{"ProviderUsed": ip.Provider},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, activities := newTestEnv()
+			env.OnGetVersion(searchAttributesChangeID, workflow.DefaultVersion, 1).Return(tt.version)
+			env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+			env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil).Maybe()
+			env.OnActivity(activities.GetLocationInfo, mock.Anything, testIP, mock.Anything).Return(testLocation, nil).Maybe()
+			env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil)
+
+			var upserts []map[string]string
+			env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+				attributes := args.Get(0).(temporal.SearchAttributes)
+				set := map[string]string{}
+				for key, value := range attributes.GetUntypedValues() {
+					if value != nil {
+						set[key.GetName()] = value.(string)
+					}
+				}
+				upserts = append(upserts, set)
+			}).Return(nil).Maybe()
+
+			env.ExecuteWorkflow(GetAddressFromIP, "Temporal", tt.opts)
+
+			got, err := workflowResult(t, env)
+			if err != nil {
+				t.Fatalf("workflow failed: %v", err)
+			}
+			if got != wantGreeting {
+				t.Errorf("result = %q, want %q", got, wantGreeting)
+			}
+			if !reflect.DeepEqual(upserts, tt.want) {
+				t.Errorf("upserts = %v, want %v", upserts, tt.want)
+			}
+			if tt.version == workflow.DefaultVersion {
+				env.AssertActivityNotCalled(t, "GetIPInfo", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func queryStatus(t *testing.T, env *testsuite.TestWorkflowEnvironment) Status {
+	t.Helper()
+
+	value, err := env.QueryWorkflow(StatusQuery)
+	if err != nil {
+		t.Fatalf("query %s: %v", StatusQuery, err)
+	}
+	var status Status
+	if err := value.Get(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	return status
+}
+
+func TestGetAddressFromIPStatusQuery(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return("", errors.New("connection reset")).Once()
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil).Once()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).After(time.Minute).Return(testInfo, nil)
+	env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil)
+
+	var during Status
+	env.RegisterDelayedCallback(func() { during = queryStatus(t, env) }, 30*time.Second)
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+	if _, err := workflowResult(t, env); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+
+	// Server side retries of GetIP do not count as another scheduled attempt
+	if during.Stage != StageGetLocationInfo || during.IP != testIP || during.Location != "" ||
+		during.Attempts[StageGetIP] != 1 || during.Attempts[StageGetLocationInfo] != 1 {
+		t.Errorf("status during GetLocationInfo = %+v", during)
+	}
+
+	after := queryStatus(t, env)
+	if after.Stage != StageCompleted || after.Location != testLocation || after.ISP != testISP || after.EnrichmentSkipped {
+		t.Errorf("status after completion = %+v", after)
+	}
+}
+
+func TestGetAddressFromIPSkipEnrichment(t *testing.T) {
+	tests := []struct {
+		name        string
+		signalAfter time.Duration
+		want        string
+	}{
+		{
+			name:        "during location lookup",
+			signalAfter: 30 * time.Second,
+			want:        "Hello, Temporal. Your IP is 203.0.113.7",
+		},
+		{
+			name:        "during ISP lookup",
+			signalAfter: 90 * time.Second,
+			want:        "Hello, Temporal. Your IP is 203.0.113.7 and your location is Austin, Texas, United States",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, activities := newTestEnv()
+			env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+			env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).After(time.Minute).Return(testInfo, nil)
+			env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).After(time.Minute).Return(testISP, nil)
+
+			env.RegisterDelayedCallback(func() {
+				env.SignalWorkflow(SkipEnrichmentSignal, nil)
+			}, tt.signalAfter)
+
+			env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+			got, err := workflowResult(t, env)
+			if err != nil {
+				t.Fatalf("workflow failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %q, want %q", got, tt.want)
+			}
+			if status := queryStatus(t, env); !status.EnrichmentSkipped {
+				t.Errorf("status = %+v, want EnrichmentSkipped", status)
+			}
+		})
+	}
+}
+
+func TestGetAddressFromIPChangeTarget(t *testing.T) {
+	const newIP = "198.51.100.20"
+
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).After(time.Minute).Return(testInfo, nil)
+	env.OnActivity(activities.GetIPInfo, mock.Anything, newIP, mock.Anything).After(time.Second).Return(ip.IPInfo{
+		City: "Lyon", RegionName: "Auvergne-Rhone-Alpes", Country: "France", ISP: "Example Telecom", Timezone: "Europe/Paris",
+	}, nil)
+
+	var (
+		updated   ChangeTargetResult
+		updateErr error
+	)
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(ChangeTargetUpdate, "correct-ip", &testsuite.TestUpdateCallback{
+			OnReject: func(err error) { updateErr = err },
+			OnComplete: func(result interface{}, err error) {
+				updateErr = err
+				if err == nil {
+					updated = result.(ChangeTargetResult)
+				}
+			},
+		}, ChangeTargetRequest{IP: newIP, Fields: []string{"timezone"}})
+	}, 30*time.Second)
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+	got, err := workflowResult(t, env)
+	if err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	if updateErr != nil {
+		t.Fatalf("update failed: %v", updateErr)
+	}
+
+	want := "Hello, Temporal. Your IP is 198.51.100.20 (Example Telecom) and your location is Lyon, Auvergne-Rhone-Alpes, France"
+	if updated.Result != want {
+		t.Errorf("update result = %q, want %q", updated.Result, want)
+	}
+	if updated.Details["timezone"] != "Europe/Paris" {
+		t.Errorf("update details = %v, want timezone Europe/Paris", updated.Details)
+	}
+	// The lookups of the replaced IP are discarded
+	if got != want {
+		t.Errorf("result = %q, want %q", got, want)
+	}
+	env.AssertActivityNotCalled(t, "GetInternetServiceProvider", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetAddressFromIPChangeTargetValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		req  ChangeTargetRequest
+	}{
+		{name: "empty", req: ChangeTargetRequest{}},
+		{name: "invalid ip", req: ChangeTargetRequest{IP: "300.1.2.3"}},
+		{name: "unknown field", req: ChangeTargetRequest{IP: "198.51.100.20", Fields: []string{"password"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, activities := newTestEnv()
+			env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+			env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).After(time.Minute).Return(testInfo, nil)
+			env.OnActivity(activities.GetInternetServiceProvider, mock.Anything, testIP, mock.Anything).Return(testISP, nil)
+
+			var rejected error
+			env.RegisterDelayedCallback(func() {
+				env.UpdateWorkflow(ChangeTargetUpdate, "bad", &testsuite.TestUpdateCallback{
+					OnAccept:   func() { t.Error("update accepted") },
+					OnReject:   func(err error) { rejected = err },
+					OnComplete: func(interface{}, error) {},
+				}, tt.req)
+			}, 30*time.Second)
+
+			env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+			got, err := workflowResult(t, env)
+			if err != nil {
+				t.Fatalf("workflow failed: %v", err)
+			}
+			if rejected == nil {
+				t.Error("update was not rejected")
+			}
+			if got != wantGreeting {
+				t.Errorf("result = %q, want %q", got, wantGreeting)
+			}
+		})
+	}
+}
+
+func TestGetAddressFromIPCancelled(t *testing.T) {
+	reporter := metrics.NewMemoryReporter()
+	var suite testsuite.WorkflowTestSuite
+	suite.SetMetricsHandler(reporter.MetricsHandler())
+	env := suite.NewTestWorkflowEnvironment()
+
+	activities := &ip.IPActivities{}
+	env.RegisterActivity(activities)
+	env.OnActivity(activities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+	env.OnActivity(activities.GetIPInfo, mock.Anything, testIP, mock.Anything).After(time.Minute).Return(testInfo, nil)
+
+	env.RegisterDelayedCallback(env.CancelWorkflow, 30*time.Second)
+
+	env.ExecuteWorkflow(GetAddressFromIP, "Temporal", Options{})
+
+	_, err := workflowResult(t, env)
+	var canceledErr *temporal.CanceledError
+	if !errors.As(err, &canceledErr) {
+		t.Fatalf("err = %v, want a canceled error", err)
+	}
+	var details CancelledResult
+	if err := canceledErr.Details(&details); err != nil {
+		t.Fatalf("decode cancelled result: %v", err)
+	}
+	if details.Status.Stage != StageGetLocationInfo || details.Status.IP != testIP {
+		t.Errorf("cancelled status = %+v", details.Status)
+	}
+	if want := "Hello, Temporal. Your IP is 203.0.113.7"; details.Result != want {
+		t.Errorf("cancelled result = %q, want %q", details.Result, want)
+	}
+
+	reporter.AssertCounter(t, "workflow_cancelled", map[string]string{"stage": StageGetLocationInfo}, 1)
+	env.AssertActivityNotCalled(t, "GetInternetServiceProvider", mock.Anything, mock.Anything, mock.Anything)
+}
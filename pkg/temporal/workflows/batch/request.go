@@ -0,0 +1,184 @@
+package batch
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+
+	enumspb "go.temporal.io/api/enums/v1"
+)
+
+const (
+	// ProgressQuery returns the Progress of a BatchEnrich execution.
+	ProgressQuery = "progress"
+
+	DefaultConcurrency = 5
+	MaxConcurrency     = 50
+
+	// DefaultLookupsPerRun is the number of IPs a run takes on before it
+	// continues as new, which keeps each run's history well below the
+	// server's event limit.
+	DefaultLookupsPerRun = 500
+
+	// MaxSeenAddresses caps the addresses a batch remembers across runs to
+	// skip as duplicates, which bounds the state carried by continue-as-new.
+	MaxSeenAddresses = 10000
+)
+
+// Result statuses
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusInvalid   = "invalid"
+)
+
+// BatchRequest is the input of BatchEnrich. Exactly one of IPs and File is set.
+type BatchRequest struct {
+	IPs []string `json:"ips,omitempty"`
+	// File is a path relative to the worker's batch directory, read one IP
+	// per line
+	File string `json:"file,omitempty"`
+
+	// Concurrency caps the lookups in flight; 0 means DefaultConcurrency
+	Concurrency int `json:"concurrency,omitempty"`
+	// LookupsPerRun is the number of IPs taken per run; 0 means
+	// DefaultLookupsPerRun
+	LookupsPerRun int `json:"lookupsPerRun,omitempty"`
+
+	// ChildWorkflows runs each lookup as an EnrichIP child workflow with a
+	// history of its own, instead of as an activity of the batch
+	ChildWorkflows bool `json:"childWorkflows,omitempty"`
+	// ParentClosePolicy is applied to the children when the batch closes
+	// before they do: "request-cancel" (default), "terminate" or "abandon"
+	ParentClosePolicy string `json:"parentClosePolicy,omitempty"`
+
+	// State carries the work of earlier runs across continue-as-new
+	State *State `json:"state,omitempty"`
+}
+
+// State is what one run of BatchEnrich hands to the next: the file cursor,
+// the counters and the hashes of the addresses looked up so far, never
+// results, so it stays bounded however large the batch. IPs still to be
+// taken from a list stay in BatchRequest.IPs.
+type State struct {
+	FileOffset int64    `json:"fileOffset,omitempty"`
+	Progress   Progress `json:"progress"`
+	// Seen holds the hashes of the first MaxSeenAddresses addresses looked up
+	Seen []uint64 `json:"seen,omitempty"`
+}
+
+// IPResult is the outcome of one IP, keyed by the normalized address, or by
+// the line as written for invalid ones.
+type IPResult struct {
+	Status      string `json:"status"`
+	Location    string `json:"location,omitempty"`
+	CountryCode string `json:"countryCode,omitempty"`
+	ISP         string `json:"isp,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Progress is the answer to ProgressQuery.
+type Progress struct {
+	// Total is the number of IPs in the request, unknown for files until the
+	// whole file was read
+	Total int `json:"total,omitempty"`
+	// Received counts the IPs taken from the list or file so far, including
+	// duplicates and invalid ones
+	Received  int `json:"received"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Invalid   int `json:"invalid"`
+	// Duplicates counts addresses already taken by this or an earlier run.
+	// Past MaxSeenAddresses distinct addresses, only repeats within a run
+	// are caught.
+	Duplicates int `json:"duplicates"`
+	InFlight   int `json:"inFlight"`
+	// Runs counts the runs that continued as new
+	Runs int  `json:"runs"`
+	Done bool `json:"done"`
+}
+
+// BatchResult is the result of BatchEnrich. Results holds the IPs of the
+// last run; each run that continued as new saved its own to the record store
+// under the keys in Records, oldest first.
+type BatchResult struct {
+	Results  map[string]IPResult `json:"results"`
+	Records  []string            `json:"records,omitempty"`
+	Progress Progress            `json:"progress"`
+}
+
+// ResultRecordKey is the record store key of the results of the run-th run
+// (counting from 0) of the batch workflowID.
+func ResultRecordKey(workflowID string, run int) string {
+	return fmt.Sprintf("%s-results-%d", workflowID, run)
+}
+
+// Validate rejects requests BatchEnrich cannot run.
+func (r BatchRequest) Validate() error {
+	if (len(r.IPs) == 0) == (r.File == "") {
+		return errors.New("exactly one of ips and file is required")
+	}
+	if r.Concurrency < 0 || r.Concurrency > MaxConcurrency {
+		return fmt.Errorf("concurrency must be between 1 and %d", MaxConcurrency)
+	}
+	if r.LookupsPerRun < 0 {
+		return errors.New("lookupsPerRun must not be negative")
+	}
+	if _, ok := parentClosePolicies[r.ParentClosePolicy]; !ok && r.ParentClosePolicy != "" {
+		return fmt.Errorf("unknown parent close policy %q", r.ParentClosePolicy)
+	}
+	return nil
+}
+
+// ValidateRecordStore rejects lists too large for a single run when the
+// record store, which every run but the last saves its results to, is
+// disabled. The size of a file is only known while it is read, so BatchEnrich
+// checks the store itself before looking up the first IP of a file that
+// needs more than one run.
+func (r BatchRequest) ValidateRecordStore(enabled bool) error {
+	r = r.withDefaults()
+	if !enabled && len(r.IPs) > r.LookupsPerRun {
+		return fmt.Errorf("batches of more than %d IPs need a record directory", r.LookupsPerRun)
+	}
+	return nil
+}
+
+var parentClosePolicies = map[string]enumspb.ParentClosePolicy{
+	"request-cancel": enumspb.PARENT_CLOSE_POLICY_REQUEST_CANCEL,
+	"terminate":      enumspb.PARENT_CLOSE_POLICY_TERMINATE,
+	"abandon":        enumspb.PARENT_CLOSE_POLICY_ABANDON,
+}
+
+func (r BatchRequest) withDefaults() BatchRequest {
+	if r.Concurrency == 0 {
+		r.Concurrency = DefaultConcurrency
+	}
+	if r.LookupsPerRun == 0 {
+		r.LookupsPerRun = DefaultLookupsPerRun
+	}
+	if r.ParentClosePolicy == "" {
+		r.ParentClosePolicy = "request-cancel"
+	}
+	if r.State == nil {
+		r.State = &State{Progress: Progress{Total: len(r.IPs)}}
+	}
+	return r
+}
+
+// normalize returns the canonical form of an IP address, so that equal
+// addresses written differently are looked up once.
+func normalize(s string) (string, bool) {
+	addr := net.ParseIP(s)
+	if addr == nil {
+		return "", false
+	}
+	return addr.String(), true
+}
+
+// addressHash is the key of a normalized address in State.Seen.
+func addressHash(addr string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(addr))
+	return h.Sum64()
+}
@@ -0,0 +1,216 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/store"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// lookupOptions give up after a few attempts so one bad IP cannot hold up the
+// batch; ip-api rejections are not retried at all.
+var lookupOptions = workflow.ActivityOptions{
+	StartToCloseTimeout: time.Minute,
+	RetryPolicy: &temporal.RetryPolicy{
+		InitialInterval:    time.Second,
+		MaximumInterval:    30 * time.Second,
+		BackoffCoefficient: 2,
+		MaximumAttempts:    5,
+	},
+}
+
+// BatchEnrich looks up every IP of a list or file with at most
+// req.Concurrency lookups in flight. Each address is looked up once however
+// often it appears, up to MaxSeenAddresses across runs, and a failed lookup
+// only fails its own IPResult.
+//
+// A run takes req.LookupsPerRun IPs and continues as new while IPs remain,
+// so no run's history grows with the size of the batch. Only the file
+// cursor, the counters and the address hashes are carried over: a run that
+// continues as new first saves its results to the record store (see
+// ResultRecordKey), and the last run returns its own.
+func BatchEnrich(ctx workflow.Context, req BatchRequest) (BatchResult, error) {
+	if err := req.Validate(); err != nil {
+		return BatchResult{}, temporal.NewNonRetryableApplicationError(err.Error(), "InvalidBatchRequest", nil)
+	}
+	req = req.withDefaults()
+	state := req.State
+	progress := &state.Progress
+	results := map[string]IPResult{}
+
+	if err := workflow.SetQueryHandler(ctx, ProgressQuery, func() (Progress, error) {
+		return *progress, nil
+	}); err != nil {
+		return BatchResult{}, err
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, lookupOptions)
+	var ipActivities *ip.IPActivities
+	var storeActivities *store.StoreActivities
+	scheduledTimeNanos := workflow.Now(ctx).UnixNano()
+
+	lookup := func(ctx workflow.Context, addr string) (IPResult, error) {
+		var info ip.IPInfo
+		err := workflow.ExecuteActivity(ctx, ipActivities.GetIPInfo, addr, scheduledTimeNanos).Get(ctx, &info)
+		return IPResult{
+			Status:      StatusSucceeded,
+			Location:    info.Location(),
+			CountryCode: info.CountryCode,
+			ISP:         info.ISP,
+		}, err
+	}
+	if req.ChildWorkflows {
+		lookup = func(ctx workflow.Context, addr string) (IPResult, error) {
+			ctx = workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+				// Addresses are unique within a run. The run ID keeps the
+				// IDs clear of children an earlier execution abandoned.
+				WorkflowID:        childWorkflowID(workflow.GetInfo(ctx).WorkflowExecution, addr),
+				ParentClosePolicy: parentClosePolicies[req.ParentClosePolicy],
+			})
+			var result IPResult
+			err := workflow.ExecuteChildWorkflow(ctx, EnrichIP, addr).Get(ctx, &result)
+			return result, err
+		}
+	}
+
+	// Take this run's share of the IPs
+	var taken []string
+	more := false
+	if req.File != "" {
+		var page ip.IPPage
+		err := workflow.ExecuteActivity(ctx, ipActivities.ReadIPs, req.File, state.FileOffset, req.LookupsPerRun, scheduledTimeNanos).Get(ctx, &page)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		taken, state.FileOffset, more = page.IPs, page.NextOffset, !page.EOF
+		if !more {
+			progress.Total = progress.Received + len(taken)
+		}
+	} else {
+		n := min(len(req.IPs), req.LookupsPerRun)
+		taken, req.IPs = req.IPs[:n], req.IPs[n:]
+		more = len(req.IPs) > 0
+	}
+	progress.Received += len(taken)
+
+	// A batch that needs more than one run cannot finish without the record
+	// store, so find out before the first lookup rather than after a run's
+	// worth of them. The empty record is replaced by the run's results.
+	if more && progress.Runs == 0 {
+		key := ResultRecordKey(workflow.GetInfo(ctx).WorkflowExecution.ID, 0)
+		err := workflow.ExecuteActivity(ctx, storeActivities.SaveRecord, key, json.RawMessage(`{}`), scheduledTimeNanos).Get(ctx, nil)
+		if err != nil {
+			return BatchResult{}, fmt.Errorf("failed to save results: %w", err)
+		}
+	}
+
+	seen := make(map[uint64]bool, len(state.Seen))
+	for _, h := range state.Seen {
+		seen[h] = true
+	}
+	var lookups []string
+	for _, line := range taken {
+		addr, ok := normalize(line)
+		if !ok {
+			results[line] = IPResult{Status: StatusInvalid, Error: "invalid IP address"}
+			progress.Invalid++
+			continue
+		}
+		h := addressHash(addr)
+		if _, ok := results[addr]; ok || seen[h] {
+			progress.Duplicates++
+			continue
+		}
+		// Reserve the address so later duplicates are skipped
+		results[addr] = IPResult{}
+		if len(state.Seen) < MaxSeenAddresses {
+			state.Seen = append(state.Seen, h)
+			seen[h] = true
+		}
+		lookups = append(lookups, addr)
+	}
+
+	sem := workflow.NewSemaphore(ctx, int64(req.Concurrency))
+	wg := workflow.NewWaitGroup(ctx)
+	for _, addr := range lookups {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return BatchResult{}, err
+		}
+		wg.Add(1)
+		progress.InFlight++
+		workflow.Go(ctx, func(ctx workflow.Context) {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			result, err := lookup(ctx, addr)
+			progress.InFlight--
+			if err != nil {
+				results[addr] = IPResult{Status: StatusFailed, Error: err.Error()}
+				progress.Failed++
+				return
+			}
+			results[addr] = result
+			progress.Succeeded++
+		})
+	}
+	wg.Wait(ctx)
+	if err := ctx.Err(); err != nil {
+		return BatchResult{}, err
+	}
+
+	if more {
+		payload, err := json.Marshal(results)
+		if err != nil {
+			return BatchResult{}, err
+		}
+		key := ResultRecordKey(workflow.GetInfo(ctx).WorkflowExecution.ID, progress.Runs)
+		err = workflow.ExecuteActivity(ctx, storeActivities.SaveRecord, key, json.RawMessage(payload), scheduledTimeNanos).Get(ctx, nil)
+		if err != nil {
+			return BatchResult{}, fmt.Errorf("failed to save results: %w", err)
+		}
+
+		progress.Runs++
+		workflow.GetLogger(ctx).Info("Continuing batch as new", "received", progress.Received, "runs", progress.Runs)
+		return BatchResult{}, workflow.NewContinueAsNewError(ctx, BatchEnrich, req)
+	}
+
+	progress.Done = true
+	result := BatchResult{Results: results, Progress: *progress}
+	for run := range progress.Runs {
+		result.Records = append(result.Records, ResultRecordKey(workflow.GetInfo(ctx).WorkflowExecution.ID, run))
+	}
+	return result, nil
+}
+
+// EnrichIP looks up the location and ISP of addr with a single GetIPInfo
+// call. BatchEnrich runs it as a child workflow per IP when
+// asked to, so each IP's retries are recorded in a history of its own and a
+// failure surfaces as the failure of that child alone.
+func EnrichIP(ctx workflow.Context, addr string) (IPResult, error) {
+	ctx = workflow.WithActivityOptions(ctx, lookupOptions)
+	var ipActivities *ip.IPActivities
+	scheduledTimeNanos := workflow.Now(ctx).UnixNano()
+
+	var info ip.IPInfo
+	err := workflow.ExecuteActivity(ctx, ipActivities.GetIPInfo, addr, scheduledTimeNanos).Get(ctx, &info)
+	if err != nil {
+		return IPResult{}, fmt.Errorf("failed to get IP info: %w", err)
+	}
+
+	return IPResult{
+		Status:      StatusSucceeded,
+		Location:    info.Location(),
+		CountryCode: info.CountryCode,
+		ISP:         info.ISP,
+	}, nil
+}
+
+// childWorkflowID returns the ID of the EnrichIP child that looks up addr for
+// the run execution.
+func childWorkflowID(execution workflow.Execution, addr string) string {
+	return execution.ID + "-" + execution.RunID + "-ip-" + addr
+}
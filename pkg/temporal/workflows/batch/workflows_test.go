@@ -0,0 +1,328 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/store"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func newTestEnv() (*testsuite.TestWorkflowEnvironment, *ip.IPActivities) {
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestWorkflowEnvironment()
+
+	activities := &ip.IPActivities{}
+	env.RegisterActivity(activities)
+	env.RegisterActivity(&store.StoreActivities{})
+	return env, activities
+}
+
+// lookup answers GetIPInfo after a minute, failing for addresses in failing
+func lookup(failing ...string) func(context.Context, string, int64) (ip.IPInfo, error) {
+	return func(_ context.Context, addr string, _ int64) (ip.IPInfo, error) {
+		for _, f := range failing {
+			if addr == f {
+				return ip.IPInfo{}, temporal.NewNonRetryableApplicationError("reserved range", "IPLookupFailed", nil)
+			}
+		}
+		return ip.IPInfo{City: "Austin", RegionName: "Texas", Country: "United States", CountryCode: "US", ISP: "Example Fiber"}, nil
+	}
+}
+
+func batchResult(t *testing.T, env *testsuite.TestWorkflowEnvironment) BatchResult {
+	t.Helper()
+
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	var result BatchResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	return result
+}
+
+func TestBatchEnrich(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, mock.Anything, mock.Anything).After(time.Minute).Return(lookup("192.0.2.1"))
+
+	inFlight, maxInFlight := 0, 0
+	env.SetOnActivityStartedListener(func(*activity.Info, context.Context, converter.EncodedValues) {
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+	})
+	env.SetOnActivityCompletedListener(func(*activity.Info, converter.EncodedValue, error) { inFlight-- })
+
+	req := BatchRequest{
+		IPs: []string{
+			"203.0.113.7", "198.51.100.20", "203.0.113.7", "192.0.2.1", "not-an-ip",
+			"2001:db8::1", "2001:0db8:0000::1", "198.51.100.21",
+		},
+		Concurrency: 2,
+	}
+	env.ExecuteWorkflow(BatchEnrich, req)
+	result := batchResult(t, env)
+
+	if maxInFlight != 2 {
+		t.Errorf("max lookups in flight = %d, want 2", maxInFlight)
+	}
+	// Equal addresses are looked up once, whatever their spelling
+	env.AssertNumberOfCalls(t, "GetIPInfo", 5)
+
+	want := Progress{Total: 8, Received: 8, Succeeded: 4, Failed: 1, Invalid: 1, Duplicates: 2, Done: true}
+	if result.Progress != want {
+		t.Errorf("progress = %+v, want %+v", result.Progress, want)
+	}
+	if r := result.Results["2001:db8::1"]; r.Status != StatusSucceeded || r.CountryCode != "US" {
+		t.Errorf("result of 2001:db8::1 = %+v", r)
+	}
+	if r := result.Results["192.0.2.1"]; r.Status != StatusFailed || r.Error == "" {
+		t.Errorf("result of 192.0.2.1 = %+v", r)
+	}
+	if r := result.Results["not-an-ip"]; r.Status != StatusInvalid {
+		t.Errorf("result of not-an-ip = %+v", r)
+	}
+}
+
+func TestBatchEnrichProgressQuery(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, mock.Anything, mock.Anything).After(time.Minute).Return(lookup())
+
+	var during Progress
+	env.RegisterDelayedCallback(func() {
+		value, err := env.QueryWorkflow(ProgressQuery)
+		if err != nil {
+			t.Fatalf("query %s: %v", ProgressQuery, err)
+		}
+		if err := value.Get(&during); err != nil {
+			t.Fatalf("decode progress: %v", err)
+		}
+	}, 90*time.Second)
+
+	env.ExecuteWorkflow(BatchEnrich, BatchRequest{
+		IPs:         []string{"203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4"},
+		Concurrency: 2,
+	})
+	batchResult(t, env)
+
+	// The first two lookups finished after a minute, the next two are running
+	want := Progress{Total: 4, Received: 4, Succeeded: 2, InFlight: 2}
+	if during != want {
+		t.Errorf("progress = %+v, want %+v", during, want)
+	}
+}
+
+func TestBatchEnrichContinueAsNew(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, mock.Anything, mock.Anything).Return(lookup())
+
+	// The first run saves its results before continuing as new
+	saved := map[string]map[string]IPResult{}
+	var storeActivities *store.StoreActivities
+	env.OnActivity(storeActivities.SaveRecord, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+		func(_ context.Context, key string, record json.RawMessage, _ int64) error {
+			var results map[string]IPResult
+			if err := json.Unmarshal(record, &results); err != nil {
+				return err
+			}
+			saved[key] = results
+			return nil
+		})
+
+	env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "batch-1"})
+	env.ExecuteWorkflow(BatchEnrich, BatchRequest{
+		IPs:           []string{"203.0.113.1", "203.0.113.2", "203.0.113.1", "203.0.113.3"},
+		LookupsPerRun: 2,
+	})
+
+	var canErr *workflow.ContinueAsNewError
+	if err := env.GetWorkflowError(); !errors.As(err, &canErr) {
+		t.Fatalf("err = %v, want continue-as-new", err)
+	}
+	var next BatchRequest
+	if err := converter.GetDefaultDataConverter().FromPayloads(canErr.Input, &next); err != nil {
+		t.Fatalf("decode continue-as-new input: %v", err)
+	}
+
+	if len(next.IPs) != 2 || next.IPs[0] != "203.0.113.1" {
+		t.Errorf("IPs left for the next run = %v", next.IPs)
+	}
+	if next.State == nil || next.State.Progress.Runs != 1 || next.State.Progress.Received != 2 || len(next.State.Seen) != 2 {
+		t.Fatalf("carried state = %+v", next.State)
+	}
+	key := ResultRecordKey("batch-1", 0)
+	if r := saved[key]; len(r) != 2 || r["203.0.113.2"].Status != StatusSucceeded {
+		t.Fatalf("saved records = %+v, want the first run's results under %q", saved, key)
+	}
+
+	// The next run skips the address the first one looked up, and returns
+	// its own results
+	env, activities = newTestEnv()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, mock.Anything, mock.Anything).Return(lookup())
+	env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "batch-1"})
+	env.ExecuteWorkflow(BatchEnrich, next)
+	result := batchResult(t, env)
+
+	env.AssertNumberOfCalls(t, "GetIPInfo", 1)
+	want := Progress{Total: 4, Received: 4, Succeeded: 3, Duplicates: 1, Runs: 1, Done: true}
+	if result.Progress != want {
+		t.Errorf("progress = %+v, want %+v", result.Progress, want)
+	}
+	if len(result.Results) != 1 || len(result.Records) != 1 || result.Records[0] != key {
+		t.Errorf("results = %+v, records = %v", result.Results, result.Records)
+	}
+}
+
+func TestBatchEnrichSaveFails(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, mock.Anything, mock.Anything).Return(lookup())
+
+	// Without a record directory the first run cannot hand its results on,
+	// which fails the batch before any lookup
+	env.ExecuteWorkflow(BatchEnrich, BatchRequest{
+		IPs:           []string{"203.0.113.1", "203.0.113.2"},
+		LookupsPerRun: 1,
+	})
+
+	var canErr *workflow.ContinueAsNewError
+	err := env.GetWorkflowError()
+	if err == nil || errors.As(err, &canErr) {
+		t.Fatalf("err = %v, want the save to fail the batch", err)
+	}
+	env.AssertNumberOfCalls(t, "GetIPInfo", 0)
+}
+
+func TestBatchEnrichFile(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.ReadIPs, mock.Anything, "ips.txt", int64(0), DefaultLookupsPerRun, mock.Anything).
+		Return(ip.IPPage{IPs: []string{"203.0.113.1", "203.0.113.2"}, NextOffset: 24, EOF: true}, nil)
+	env.OnActivity(activities.GetIPInfo, mock.Anything, mock.Anything, mock.Anything).Return(lookup())
+
+	env.ExecuteWorkflow(BatchEnrich, BatchRequest{File: "ips.txt"})
+	result := batchResult(t, env)
+
+	want := Progress{Total: 2, Received: 2, Succeeded: 2, Done: true}
+	if result.Progress != want {
+		t.Errorf("progress = %+v, want %+v", result.Progress, want)
+	}
+}
+
+func TestBatchEnrichChildWorkflows(t *testing.T) {
+	env, activities := newTestEnv()
+	env.RegisterWorkflow(EnrichIP)
+	env.OnActivity(activities.GetIPInfo, mock.Anything, mock.Anything, mock.Anything).Return(lookup("192.0.2.1"))
+
+	var children []string
+	env.SetOnChildWorkflowStartedListener(func(info *workflow.Info, _ workflow.Context, _ converter.EncodedValues) {
+		// Child IDs carry the parent's run ID
+		parent := info.ParentWorkflowExecution
+		if parent == nil || parent.RunID == "" || !strings.HasPrefix(info.WorkflowExecution.ID, parent.ID+"-"+parent.RunID+"-ip-") {
+			t.Errorf("child ID %q, parent %+v", info.WorkflowExecution.ID, parent)
+		}
+		children = append(children, info.WorkflowExecution.ID)
+	})
+
+	env.ExecuteWorkflow(BatchEnrich, BatchRequest{
+		IPs:            []string{"203.0.113.7", "192.0.2.1", "198.51.100.20"},
+		ChildWorkflows: true,
+	})
+	result := batchResult(t, env)
+
+	if len(children) != 3 {
+		t.Errorf("children started = %v, want one per IP", children)
+	}
+	want := Progress{Total: 3, Received: 3, Succeeded: 2, Failed: 1, Done: true}
+	if result.Progress != want {
+		t.Errorf("progress = %+v, want %+v", result.Progress, want)
+	}
+	// The failing child does not keep its siblings from completing
+	for _, addr := range []string{"203.0.113.7", "198.51.100.20"} {
+		if r := result.Results[addr]; r.Status != StatusSucceeded || r.ISP != "Example Fiber" {
+			t.Errorf("result of %s = %+v", addr, r)
+		}
+	}
+	if r := result.Results["192.0.2.1"]; r.Status != StatusFailed || r.Error == "" {
+		t.Errorf("result of 192.0.2.1 = %+v", r)
+	}
+}
+
+func TestEnrichIP(t *testing.T) {
+	env, activities := newTestEnv()
+	env.OnActivity(activities.GetIPInfo, mock.Anything, "203.0.113.7", mock.Anything).Return(lookup())
+
+	env.ExecuteWorkflow(EnrichIP, "203.0.113.7")
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	var result IPResult
+	if err := env.GetWorkflowResult(&result); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+
+	want := IPResult{Status: StatusSucceeded, Location: "Austin, Texas, United States", CountryCode: "US", ISP: "Example Fiber"}
+	if result != want {
+		t.Errorf("result = %+v, want %+v", result, want)
+	}
+	// The ISP comes with the location, without a lookup of its own
+	env.AssertNumberOfCalls(t, "GetIPInfo", 1)
+	env.AssertNumberOfCalls(t, "GetInternetServiceProvider", 0)
+}
+
+func TestBatchRequestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  BatchRequest
+	}{
+		{name: "empty", req: BatchRequest{}},
+		{name: "ips and file", req: BatchRequest{IPs: []string{"203.0.113.7"}, File: "ips.txt"}},
+		{name: "concurrency too high", req: BatchRequest{IPs: []string{"203.0.113.7"}, Concurrency: MaxConcurrency + 1}},
+		{name: "unknown parent close policy", req: BatchRequest{IPs: []string{"203.0.113.7"}, ParentClosePolicy: "orphan"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.req.Validate(); err == nil {
+				t.Error("Validate succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestBatchRequestValidateRecordStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     BatchRequest
+		enabled bool
+		wantErr bool
+	}{
+		{name: "single run", req: BatchRequest{IPs: []string{"203.0.113.1", "203.0.113.2"}}},
+		{name: "several runs", req: BatchRequest{IPs: []string{"203.0.113.1", "203.0.113.2"}, LookupsPerRun: 1}, wantErr: true},
+		{name: "several runs with a store", req: BatchRequest{IPs: []string{"203.0.113.1", "203.0.113.2"}, LookupsPerRun: 1}, enabled: true},
+		// The workflow checks files, whose size is not known up front
+		{name: "file", req: BatchRequest{File: "ips.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.ValidateRecordStore(tt.enabled)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package drift
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/webhook"
+	"github.com/natemollica-nm/temporal/pkg/temporal/shared"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// DriftEvent is the event of the webhook sent when the egress IP drifted.
+const DriftEvent = "ip.drift"
+
+// Fields compared between runs
+const (
+	FieldIP          = "ip"
+	FieldLocation    = "location"
+	FieldCountryCode = "countryCode"
+	FieldISP         = "isp"
+)
+
+// MonitorInput is the input of IPDriftMonitor, fixed by the schedule starting
+// it.
+type MonitorInput struct {
+	// WebhookURL is notified of drift; empty only records the metric
+	WebhookURL string `json:"webhookUrl,omitempty"`
+}
+
+// Observation is what one run found out about the egress IP.
+type Observation struct {
+	IP          string    `json:"ip"`
+	Location    string    `json:"location"`
+	CountryCode string    `json:"countryCode"`
+	ISP         string    `json:"isp"`
+	ObservedAt  time.Time `json:"observedAt"`
+}
+
+// Report is the result of IPDriftMonitor. The next scheduled run compares its
+// observation with Current.
+type Report struct {
+	Current Observation `json:"current"`
+	// Previous is the observation of the last run that completed, nil on the
+	// first run of the schedule
+	Previous *Observation `json:"previous,omitempty"`
+	// Changed names the fields that differ from Previous
+	Changed []string `json:"changed,omitempty"`
+	// Notified tells whether the webhook accepted the drift; NotifyError is
+	// why it did not
+	Notified    bool   `json:"notified,omitempty"`
+	NotifyError string `json:"notifyError,omitempty"`
+}
+
+// changed returns the fields of o that differ from prev.
+func (o Observation) changed(prev Observation) []string {
+	var fields []string
+	if o.IP != prev.IP {
+		fields = append(fields, FieldIP)
+	}
+	if o.Location != prev.Location {
+		fields = append(fields, FieldLocation)
+	}
+	if o.CountryCode != prev.CountryCode {
+		fields = append(fields, FieldCountryCode)
+	}
+	if o.ISP != prev.ISP {
+		fields = append(fields, FieldISP)
+	}
+	return fields
+}
+
+// IPDriftMonitor looks up the egress IP and its details and compares them
+// with the result of the schedule's last completed run. Drift is counted in
+// the ip_drift_detected metric and, with a webhook URL, posted to it.
+//
+// A webhook that fails for good does not fail the run: the failure is
+// recorded in the report, and the observation still becomes the baseline of
+// the next run, so one dead receiver cannot stop the comparisons.
+func IPDriftMonitor(ctx workflow.Context, input MonitorInput) (Report, error) {
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			MaximumInterval:    30 * time.Second,
+			BackoffCoefficient: 2,
+			MaximumAttempts:    5,
+		},
+	})
+	logger := workflow.GetLogger(ctx)
+	var ipActivities *ip.IPActivities
+	var webhookActivities *webhook.WebhookActivities
+	scheduledTimeNanos := workflow.Now(ctx).UnixNano()
+
+	var report Report
+	if workflow.HasLastCompletionResult(ctx) {
+		var last Report
+		if err := workflow.GetLastCompletionResult(ctx, &last); err != nil {
+			return Report{}, err
+		}
+		report.Previous = &last.Current
+	}
+
+	current := &report.Current
+	current.ObservedAt = workflow.Now(ctx).UTC()
+	if err := workflow.ExecuteActivity(ctx, ipActivities.GetIP, scheduledTimeNanos).Get(ctx, &current.IP); err != nil {
+		return Report{}, err
+	}
+	var info ip.IPInfo
+	if err := workflow.ExecuteActivity(ctx, ipActivities.GetIPInfo, current.IP, scheduledTimeNanos).Get(ctx, &info); err != nil {
+		return Report{}, err
+	}
+	current.Location, current.CountryCode, current.ISP = info.Location(), info.CountryCode, info.ISP
+
+	if report.Previous == nil {
+		logger.Info("Recorded first observation", "ip", current.IP)
+		return report, nil
+	}
+	report.Changed = current.changed(*report.Previous)
+	if len(report.Changed) == 0 {
+		return report, nil
+	}
+
+	logger.Info("Egress IP drifted", "changed", report.Changed, "ip", current.IP, "previousIP", report.Previous.IP)
+	shared.RecordIPDrift(workflow.GetMetricsHandler(ctx), report.Changed)
+
+	if input.WebhookURL != "" {
+		payload, err := json.Marshal(report)
+		if err != nil {
+			return Report{}, err
+		}
+		err = workflow.ExecuteActivity(ctx, webhookActivities.NotifyWebhook, input.WebhookURL, DriftEvent, json.RawMessage(payload), scheduledTimeNanos).Get(ctx, nil)
+		if err != nil {
+			logger.Error("Drift notification failed", "error", err)
+			report.NotifyError = err.Error()
+			return report, nil
+		}
+		report.Notified = true
+	}
+	return report, nil
+}
@@ -0,0 +1,127 @@
+package drift
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/natemollica-nm/temporal/internal/metrics"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/ip"
+	"github.com/natemollica-nm/temporal/pkg/temporal/activities/webhook"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/testsuite"
+)
+
+const (
+	testIP     = "203.0.113.7"
+	testURL    = "https://hooks.example.com/drift"
+	driftCount = "ip_drift_detected"
+)
+
+var testInfo = ip.IPInfo{City: "Austin", RegionName: "Texas", Country: "United States", CountryCode: "US", ISP: "Example Fiber"}
+
+func newTestEnv(reporter *metrics.MemoryReporter) (*testsuite.TestWorkflowEnvironment, *ip.IPActivities, *webhook.WebhookActivities) {
+	var suite testsuite.WorkflowTestSuite
+	suite.SetMetricsHandler(reporter.MetricsHandler())
+	env := suite.NewTestWorkflowEnvironment()
+
+	ipActivities := &ip.IPActivities{}
+	webhookActivities := &webhook.WebhookActivities{}
+	env.RegisterActivity(ipActivities)
+	env.RegisterActivity(webhookActivities)
+	env.OnActivity(ipActivities.GetIP, mock.Anything, mock.Anything).Return(testIP, nil)
+	env.OnActivity(ipActivities.GetIPInfo, mock.Anything, testIP, mock.Anything).Return(testInfo, nil)
+	return env, ipActivities, webhookActivities
+}
+
+func report(t *testing.T, env *testsuite.TestWorkflowEnvironment) Report {
+	t.Helper()
+
+	if !env.IsWorkflowCompleted() {
+		t.Fatal("workflow did not complete")
+	}
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatalf("workflow failed: %v", err)
+	}
+	var r Report
+	if err := env.GetWorkflowResult(&r); err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	return r
+}
+
+func TestIPDriftMonitor(t *testing.T) {
+	same := Observation{IP: testIP, Location: "Austin, Texas, United States", CountryCode: "US", ISP: "Example Fiber"}
+	moved := Observation{IP: "198.51.100.20", Location: "Lyon, Auvergne-Rhone-Alpes, France", CountryCode: "FR", ISP: "Example Fiber"}
+
+	tests := []struct {
+		name        string
+		last        *Observation
+		webhookURL  string
+		wantChanged []string
+		wantWebhook bool
+	}{
+		{name: "first run", webhookURL: testURL},
+		{name: "unchanged", last: &same, webhookURL: testURL},
+		{name: "drifted", last: &moved, webhookURL: testURL, wantChanged: []string{FieldIP, FieldLocation, FieldCountryCode}, wantWebhook: true},
+		{name: "drifted without webhook", last: &moved, wantChanged: []string{FieldIP, FieldLocation, FieldCountryCode}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter := metrics.NewMemoryReporter()
+			env, _, webhookActivities := newTestEnv(reporter)
+			env.OnActivity(webhookActivities.NotifyWebhook, mock.Anything, testURL, DriftEvent, mock.Anything, mock.Anything).Return(nil).Maybe()
+			if tt.last != nil {
+				env.SetLastCompletionResult(Report{Current: *tt.last})
+			}
+
+			env.ExecuteWorkflow(IPDriftMonitor, MonitorInput{WebhookURL: tt.webhookURL})
+			r := report(t, env)
+
+			if r.Current != (Observation{IP: testIP, Location: same.Location, CountryCode: "US", ISP: "Example Fiber", ObservedAt: r.Current.ObservedAt}) {
+				t.Errorf("current = %+v", r.Current)
+			}
+			if (r.Previous == nil) != (tt.last == nil) {
+				t.Errorf("previous = %+v, want %+v", r.Previous, tt.last)
+			}
+			if !slices.Equal(r.Changed, tt.wantChanged) {
+				t.Errorf("changed = %v, want %v", r.Changed, tt.wantChanged)
+			}
+
+			for _, field := range []string{FieldIP, FieldLocation, FieldCountryCode, FieldISP} {
+				var want int64
+				if slices.Contains(tt.wantChanged, field) {
+					want = 1
+				}
+				reporter.AssertCounter(t, driftCount, map[string]string{"field": field}, want)
+			}
+			if tt.wantWebhook {
+				env.AssertNumberOfCalls(t, "NotifyWebhook", 1)
+				if !r.Notified {
+					t.Error("notified = false after the webhook accepted the drift")
+				}
+			} else {
+				env.AssertNumberOfCalls(t, "NotifyWebhook", 0)
+			}
+		})
+	}
+}
+
+func TestIPDriftMonitorWebhookFails(t *testing.T) {
+	reporter := metrics.NewMemoryReporter()
+	env, _, webhookActivities := newTestEnv(reporter)
+	env.OnActivity(webhookActivities.NotifyWebhook, mock.Anything, testURL, DriftEvent, mock.Anything, mock.Anything).Return(errors.New("webhook answered 503 Service Unavailable"))
+	env.SetLastCompletionResult(Report{Current: Observation{IP: "198.51.100.20"}})
+
+	env.ExecuteWorkflow(IPDriftMonitor, MonitorInput{WebhookURL: testURL})
+
+	// The run completes, so its observation is the next run's baseline
+	r := report(t, env)
+	if r.Notified || !strings.Contains(r.NotifyError, "503") {
+		t.Errorf("notified = %v, notify error = %q", r.Notified, r.NotifyError)
+	}
+	// Drift is counted whether or not the webhook took it
+	reporter.AssertCounter(t, driftCount, map[string]string{"field": FieldIP}, 1)
+}